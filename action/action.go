@@ -1,11 +1,17 @@
 package action
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/cnabio/cnab-go/bundle"
 	"github.com/cnabio/cnab-go/bundle/definition"
@@ -17,6 +23,12 @@ import (
 // stateful is there just to make callers of opFromClaims more readable
 const stateful = false
 
+// modifying and readOnly are there just to make callers of Options.run more readable
+const (
+	modifying = true
+	readOnly  = false
+)
+
 // Action describes one of the primary actions that can be executed in CNAB.
 //
 // The actions are:
@@ -30,6 +42,214 @@ type Action interface {
 	Run(*claim.Claim, credentials.Set, ...OperationConfigFunc) error
 }
 
+// Result is the outcome of an action that does not modify the installation.
+type Result struct {
+	// Action is the name of the action that was run.
+	Action string
+	// Status is claim.StatusSuccess when the invocation image completed
+	// successfully, and claim.StatusFailure otherwise.
+	Status string
+	// Message is the error reported by the driver, if any.
+	Message string
+	// ExitCode is the exit code of the invocation image, when the driver
+	// reported it.
+	ExitCode *int
+	// Driver is the name of the driver that ran the operation.
+	Driver string
+	// OperationID correlates the operation with the resources the driver ran
+	// it with, such as the name of a Kubernetes job or the ID of a Docker
+	// container.
+	OperationID string
+	// Outputs maps the names of the outputs that apply to the action to their
+	// contents, validated against their definitions.
+	Outputs map[string]interface{}
+	// Logs holds the output of the invocation image, when it was captured.
+	Logs string
+}
+
+// newResult builds the result of a read-only action from the driver's operation result.
+func (o Options) newResult(action string, b *bundle.Bundle, opResult *driver.OperationResult, runErr error) (*Result, error) {
+	if opResult == nil {
+		// The driver never ran, so there is nothing to report.
+		return nil, runErr
+	}
+
+	res := &Result{
+		Action:      action,
+		Status:      claim.StatusSuccess,
+		ExitCode:    opResult.ExitCode,
+		Driver:      opResult.Driver,
+		OperationID: opResult.OperationID,
+	}
+	if runErr != nil {
+		res.Status = claim.StatusFailure
+		res.Message = runErr.Error()
+	}
+
+	outputs, err := validateOutputs(b, action, opResult.Outputs, o.maxOutputSize())
+	res.Outputs = outputs
+	if runErr != nil {
+		return res, runErr
+	}
+	return res, err
+}
+
+// run executes the action against the claim with the given driver, notifying
+// observers and post-run hooks along the way.
+//
+// When modifies is true, the outputs and the result of the operation are
+// recorded on the claim, even when the driver fails, so that users can see the
+// output files.
+//
+// The returned operation result is nil when the action failed before the
+// driver ran.
+func (o Options) run(d driver.Driver, action string, stateless, modifies bool, c *claim.Claim, creds credentials.Set, opCfgs []OperationConfigFunc) (_ *driver.OperationResult, err error) {
+	var (
+		invocImage bundle.InvocationImage
+		op         *driver.Operation
+	)
+	event := func(t EventType) Event {
+		e := Event{Type: t, Action: action, Installation: c.Name, Claim: c, Operation: op}
+		if invocImage.Image != "" {
+			e.Image = &invocImage
+		}
+		return e
+	}
+	defer func() {
+		if err != nil {
+			e := event(EventActionFailed)
+			e.Error = err
+			o.notify(e)
+			return
+		}
+		o.notify(event(EventActionSucceeded))
+	}()
+
+	// Read-only actions, such as status checks, do not write the claim, so
+	// they run alongside other actions rather than failing on their lock.
+	if modifies {
+		unlock, lockErr := o.Lock(c.Name)
+		if lockErr != nil {
+			return nil, lockErr
+		}
+		defer func() {
+			if unlockErr := unlock(); unlockErr != nil && err == nil {
+				err = unlockErr
+			}
+		}()
+	}
+
+	invocImage, err = selectInvocationImage(d, c)
+	if err != nil {
+		return nil, err
+	}
+	o.notify(event(EventInvocationImageSelected))
+
+	op, err = opFromClaim(action, stateless, c, invocImage, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := c.Revision
+	err = OperationConfigs(opCfgs).ApplyConfig(op)
+	if err != nil {
+		return nil, err
+	}
+	// The configuration functions may have recorded the claim as underway,
+	// so from now on it is failed when the driver cannot be started.
+	setupFailed := func(err error) error {
+		if modifies {
+			c.Update(action, claim.StatusFailure)
+			c.Result.Message = err.Error()
+		}
+		return err
+	}
+	if modifies {
+		c.Labels, c.Annotations = op.Labels, op.Annotations
+	}
+	if err = setClaim(op, c); err != nil {
+		return nil, setupFailed(err)
+	}
+
+	var logRevision string
+	if modifies && o.Logs != nil {
+		var closeLog func() error
+		logRevision, closeLog, err = o.teeLog(op, previous)
+		if err != nil {
+			return nil, setupFailed(err)
+		}
+		defer func() {
+			if logErr := closeLog(); logErr != nil && err == nil {
+				err = logErr
+			}
+		}()
+	}
+	o.notify(event(EventOperationBuilt))
+
+	started := time.Now()
+	opResult, attempts, err := o.runDriver(d, op, func(attempt int) {
+		e := event(EventDriverStarted)
+		e.Attempt = attempt
+		o.notify(e)
+	})
+	stopped := time.Now()
+
+	// If this action does not modify the installation, then we don't track
+	// it in the claim.
+	if !modifies {
+		if hookErr := o.postRun(c, opResult); hookErr != nil && err == nil {
+			err = hookErr
+		}
+		return &opResult, err
+	}
+
+	outputErrors := setOutputsOnClaim(c, opResult.Outputs, o.maxOutputSize())
+	for name, value := range c.Outputs {
+		e := event(EventOutputCaptured)
+		e.OutputName = name
+		e.OutputValue = value
+		o.notify(e)
+	}
+	for name := range c.WriteOnlyOutputs {
+		// The value of write-only outputs is not given to observers.
+		e := event(EventOutputCaptured)
+		e.OutputName = name
+		o.notify(e)
+	}
+
+	if err != nil {
+		c.Update(action, claim.StatusFailure)
+		c.Result.Message = err.Error()
+	} else {
+		c.Update(action, claim.StatusSuccess)
+	}
+	c.Result.Attempts = attempts
+	c.Result.LogRevision = logRevision
+	recordOperation(&c.Result, opResult, started, stopped)
+
+	if hookErr := o.postRun(c, opResult); hookErr != nil && err == nil {
+		c.Result.Status = claim.StatusFailure
+		c.Result.Message = hookErr.Error()
+		err = hookErr
+	}
+	o.notify(event(EventClaimUpdated))
+
+	if err != nil {
+		return &opResult, err
+	}
+	return &opResult, outputErrors
+}
+
+// recordOperation records how the operation ran on the result of the claim.
+func recordOperation(res *claim.Result, opResult driver.OperationResult, started, stopped time.Time) {
+	res.Started = &started
+	res.Stopped = &stopped
+	res.Driver = opResult.Driver
+	res.ImageDigest = opResult.ImageDigest
+	res.ExitCode = opResult.ExitCode
+	res.OperationID = opResult.OperationID
+}
+
 func golangTypeToJSONType(value interface{}) (string, error) {
 	switch v := value.(type) {
 	case nil:
@@ -113,44 +333,158 @@ func isTypeOk(name, content string, allowedTypes map[string]bool) error {
 	return nil
 }
 
-func setOutputsOnClaim(claim *claim.Claim, outputs map[string]string) error {
+// DefaultMaxOutputSize is the maximum size, in bytes, of an output file when
+// Options.MaxOutputSize is not set.
+const DefaultMaxOutputSize = 1024 * 1024
+
+func (o Options) maxOutputSize() int {
+	if o.MaxOutputSize > 0 {
+		return o.MaxOutputSize
+	}
+	return DefaultMaxOutputSize
+}
+
+// setOutputsOnClaim records the outputs on the claim. Outputs whose definition
+// is write-only are recorded in claim.WriteOnlyOutputs, so that they are not
+// stored in the claim body. Binary outputs, and outputs whose definition has a
+// base64 contentEncoding, are recorded as their base64 encoding, which survives
+// the JSON encoding of the claim, and their encoding is recorded in
+// claim.OutputEncodings.
+func setOutputsOnClaim(c *claim.Claim, outputs map[string]string, maxSize int) error {
+	values, err := validateOutputs(c.Bundle, "", outputs, maxSize)
+	c.Outputs = map[string]interface{}{}
+	c.WriteOnlyOutputs = nil
+	c.OutputEncodings = nil
+	for name, value := range values {
+		b, binary := value.([]byte)
+		if binary {
+			value = base64.StdEncoding.EncodeToString(b)
+		}
+		if binary || isBase64Output(c.Bundle, name) {
+			if c.OutputEncodings == nil {
+				c.OutputEncodings = map[string]string{}
+			}
+			c.OutputEncodings[name] = claim.OutputEncodingBase64
+		}
+		if !isWriteOnlyOutput(c.Bundle, name) {
+			c.Outputs[name] = value
+			continue
+		}
+		if c.WriteOnlyOutputs == nil {
+			c.WriteOnlyOutputs = map[string]interface{}{}
+		}
+		c.WriteOnlyOutputs[name] = value
+	}
+	return err
+}
+
+func isWriteOnlyOutput(b *bundle.Bundle, name string) bool {
+	schema := b.Definitions[b.Outputs[name].Definition]
+	return schema != nil && schema.WriteOnly != nil && *schema.WriteOnly
+}
+
+func isBase64Output(b *bundle.Bundle, name string) bool {
+	schema := b.Definitions[b.Outputs[name].Definition]
+	return schema != nil && schema.ContentEncoding == "base64"
+}
+
+// validateOutputs checks the contents of the output files returned by a driver
+// against the output definitions of the bundle, and returns them keyed by output
+// name, decoded into the type of their definition. When action is not empty,
+// only the outputs that apply to it are kept.
+func validateOutputs(b *bundle.Bundle, action string, outputs map[string]string, maxSize int) (map[string]interface{}, error) {
 	var outputErrors []error
-	claim.Outputs = map[string]interface{}{}
+	values := map[string]interface{}{}
 
-	if claim.Bundle.Outputs == nil {
-		return nil
+	if b.Outputs == nil {
+		return values, nil
 	}
 
-	for outputName, v := range claim.Bundle.Outputs {
+	for outputName, v := range b.Outputs {
+		if action != "" && !v.AppliesTo(action) {
+			continue
+		}
+
 		name := v.Definition
 		if name == "" {
-			return fmt.Errorf("invalid bundle: no definition set for output %q", outputName)
+			return values, fmt.Errorf("invalid bundle: no definition set for output %q", outputName)
 		}
 
-		outputSchema := claim.Bundle.Definitions[name]
+		outputSchema := b.Definitions[name]
 		if outputSchema == nil {
-			return fmt.Errorf("invalid bundle: output %q references definition %q, which was not found", outputName, name)
+			return values, fmt.Errorf("invalid bundle: output %q references definition %q, which was not found", outputName, name)
 		}
 		outputTypes, err := allowedTypes(*outputSchema)
 		if err != nil {
-			return err
+			return values, err
 		}
 
 		content := outputs[v.Path]
-		if content != "" {
-			err := isTypeOk(outputName, content, outputTypes)
-			if err != nil {
-				outputErrors = append(outputErrors, err)
-			}
-			claim.Outputs[outputName] = outputs[v.Path]
+		if content == "" {
+			continue
+		}
+		if len(content) > maxSize {
+			outputErrors = append(outputErrors, fmt.Errorf("%q is %d bytes, which exceeds the limit of %d bytes", outputName, len(content), maxSize))
+			continue
 		}
+		value, err := decodeOutput(outputName, content, outputSchema, outputTypes)
+		if err != nil {
+			outputErrors = append(outputErrors, err)
+			value = content
+		}
+		values[outputName] = value
 	}
 
 	if len(outputErrors) > 0 {
-		return fmt.Errorf("error: %s", outputErrors)
+		return values, fmt.Errorf("error: %s", outputErrors)
 	}
 
-	return nil
+	return values, nil
+}
+
+// decodeOutput converts the contents of an output file to the type of its
+// definition.
+//
+// Binary contents are base64 encoded when the definition has a base64
+// contentEncoding, and kept as bytes when the definition is a string otherwise.
+// Strings are the escape hatch for non-JSON outputs: when the definition allows
+// a string, contents that do not decode to another allowed type are kept as is.
+func decodeOutput(name, content string, schema *definition.Schema, allowedTypes map[string]bool) (interface{}, error) {
+	if schema.ContentEncoding == "base64" {
+		return base64.StdEncoding.EncodeToString([]byte(content)), nil
+	}
+
+	if allowedTypes["string"] {
+		if !utf8.ValidString(content) {
+			return []byte(content), nil
+		}
+		var value interface{}
+		if err := json.Unmarshal([]byte(content), &value); err != nil {
+			return content, nil
+		}
+		if t, err := golangTypeToJSONType(value); err != nil || t == "string" || !allowedTypes[t] {
+			return content, nil
+		}
+		return coerceOutput(value, allowedTypes), nil
+	}
+
+	if err := isTypeOk(name, content, allowedTypes); err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %s", name, err)
+	}
+	return coerceOutput(value, allowedTypes), nil
+}
+
+// coerceOutput turns whole numbers into integers when the definition expects
+// integers but not numbers.
+func coerceOutput(value interface{}, allowedTypes map[string]bool) interface{} {
+	if f, ok := value.(float64); ok && allowedTypes["integer"] && !allowedTypes["number"] && math.Trunc(f) == f {
+		return int(f)
+	}
+	return value
 }
 
 func selectInvocationImage(d driver.Driver, c *claim.Claim) (bundle.InvocationImage, error) {
@@ -176,7 +510,7 @@ func getImageMap(b *bundle.Bundle) ([]byte, error) {
 }
 
 func opFromClaim(action string, stateless bool, c *claim.Claim, ii bundle.InvocationImage, creds credentials.Set) (*driver.Operation, error) {
-	env, files, err := creds.Expand(c.Bundle, stateless)
+	env, files, err := creds.Expand(c.Bundle, action, stateless)
 	if err != nil {
 		return nil, err
 	}
@@ -188,7 +522,21 @@ func opFromClaim(action string, stateless bool, c *claim.Claim, ii bundle.Invoca
 		}
 	}
 
-	if err := injectParameters(action, c, env, files); err != nil {
+	params, err := resolveParameters(action, c.Bundle, c.Parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	vars, err := credentialEnv(action, c.Bundle, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := injectParameters(c.Bundle, params, vars, env, files); err != nil {
+		return nil, err
+	}
+
+	if err := injectPreviousOutputs(c, files); err != nil {
 		return nil, err
 	}
 
@@ -220,53 +568,272 @@ func opFromClaim(action string, stateless bool, c *claim.Claim, ii bundle.Invoca
 		}
 	}
 
-	return &driver.Operation{
+	op := &driver.Operation{
 		Action:       action,
 		Installation: c.Name,
-		Parameters:   c.Parameters,
+		Parameters:   params,
 		Image:        ii,
 		Revision:     c.Revision,
 		Environment:  env,
 		Files:        files,
 		Outputs:      outputs,
 		Bundle:       c.Bundle,
-	}, nil
+		Labels:       merge(c.Labels, nil),
+		Annotations:  merge(c.Annotations, nil),
+	}
+	if err := setClaim(op, c); err != nil {
+		return nil, err
+	}
+	return op, nil
 }
 
-func injectParameters(action string, c *claim.Claim, env, files map[string]string) error {
-	for k, param := range c.Bundle.Parameters {
-		rawval, ok := c.Parameters[k]
+// setClaim sets the revision and the claim given to the invocation image from
+// the operation and the claim. It is called again once the configuration
+// functions ran, since they may update both, as the installation manager does
+// when it saves the underway claim.
+func setClaim(op *driver.Operation, c *claim.Claim) error {
+	claimBytes, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to marshal claim: %s", err)
+	}
+	if op.Files == nil {
+		op.Files = map[string]string{}
+	}
+	op.Files[claimFile] = string(claimBytes)
+
+	if op.Environment == nil {
+		op.Environment = map[string]string{}
+	}
+	op.Environment["CNAB_REVISION"] = op.Revision
+	return nil
+}
+
+// resolveParameters returns the parameter values to use for the action.
+//
+// Only the parameters that apply to the action are kept. Values are coerced to
+// the type of their definition and validated against it, and defaults are
+// applied to parameters that were not given.
+func resolveParameters(action string, b *bundle.Bundle, values map[string]interface{}) (map[string]interface{}, error) {
+	params := map[string]interface{}{}
+	for name, param := range b.Parameters {
+		if !param.AppliesTo(action) {
+			continue
+		}
+
+		schema, ok := b.Definitions[param.Definition]
+		if !ok || schema == nil {
+			return nil, fmt.Errorf("invalid bundle: parameter %q references definition %q, which was not found", name, param.Definition)
+		}
+
+		value, ok := values[name]
 		if !ok {
-			if param.Required && param.AppliesTo(action) {
-				return fmt.Errorf("missing required parameter %q for action %q", k, action)
+			if param.Required {
+				return nil, fmt.Errorf("missing required parameter %q for action %q", name, action)
 			}
-			continue
+			if schema.Default == nil {
+				continue
+			}
+			value = schema.Default
+		}
+
+		value, err := coerceParameter(schema, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for parameter %q: %s", name, err)
 		}
 
-		contents, err := json.Marshal(rawval)
+		valErrs, err := schema.Validate(value)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("unable to validate parameter %q: %s", name, err)
+		}
+		if len(valErrs) > 0 {
+			var msgs []string
+			for _, valErr := range valErrs {
+				msgs = append(msgs, valErr.Error)
+			}
+			return nil, fmt.Errorf("invalid value for parameter %q: %s", name, strings.Join(msgs, "; "))
+		}
+
+		params[name] = value
+	}
+	return params, nil
+}
+
+// coerceParameter converts string values to the scalar type of the definition,
+// and whole numbers to integers when the definition expects an integer.
+func coerceParameter(schema *definition.Schema, value interface{}) (interface{}, error) {
+	if str, ok := value.(string); ok {
+		if dataType, ok, _ := schema.GetType(); ok {
+			switch dataType {
+			case "integer", "boolean":
+				return schema.ConvertValue(str)
+			case "number":
+				return strconv.ParseFloat(str, 64)
+			}
+		}
+	}
+	return schema.CoerceValue(value), nil
+}
+
+const (
+	// claimFile is where the claim is mounted in the invocation image.
+	claimFile = "/cnab/claim.json"
+	// previousOutputsDir is where the outputs recorded on the claim by
+	// previous actions are mounted in the invocation image, one file per output.
+	previousOutputsDir = "/cnab/app/previous-outputs"
+)
+
+// reservedEnv lists the environment variables set by the runtime or the
+// drivers, which parameters and credentials may not use.
+var reservedEnv = map[string]bool{
+	"CNAB_INSTALLATION_NAME": true,
+	"CNAB_ACTION":            true,
+	"CNAB_BUNDLE_NAME":       true,
+	"CNAB_BUNDLE_VERSION":    true,
+	"CNAB_REVISION":          true,
+	"CNAB_OUTPUT_DIR":        true,
+	"CNAB_VARS":              true,
+}
+
+// envVars records which parameter or credential uses each environment
+// variable, in order to detect collisions.
+type envVars map[string]string
+
+func (e envVars) add(name, owner string) error {
+	if reservedEnv[name] {
+		return fmt.Errorf("%s cannot use the reserved environment variable %s", owner, name)
+	}
+	if other, ok := e[name]; ok {
+		return fmt.Errorf("%s and %s both use the environment variable %s", other, owner, name)
+	}
+	e[name] = owner
+	return nil
+}
+
+// credentialEnv returns the environment variables used by the supplied
+// credentials that apply to the action.
+func credentialEnv(action string, b *bundle.Bundle, creds credentials.Set) (envVars, error) {
+	vars := envVars{}
+	for _, name := range sortedCredentialNames(b.Credentials) {
+		cred := b.Credentials[name]
+		if _, ok := creds[name]; !ok || cred.EnvironmentVariable == "" || !cred.AppliesTo(action) {
+			continue
 		}
+		if err := vars.add(cred.EnvironmentVariable, fmt.Sprintf("credential %q", name)); err != nil {
+			return nil, err
+		}
+	}
+	return vars, nil
+}
+
+// paramEnvName returns the environment variable of a parameter without a
+// destination: CNAB_P_ followed by the upper-cased name, where every character
+// that is not a letter, a digit or an underscore is replaced by an underscore.
+func paramEnvName(name string) string {
+	return "CNAB_P_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func sortedCredentialNames(m map[string]bundle.Credential) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatValue returns the value as written to an environment variable or a
+// file: strings are kept as is, other values are marshaled to JSON.
+func formatValue(rawval interface{}) (string, error) {
+	if bytes, ok := rawval.([]byte); ok {
+		return string(bytes), nil
+	}
 
-		// In order to preserve the exact string value the user provided
-		// we don't marshal string parameters
-		value := string(contents)
-		if value[0] == '"' {
-			value, ok = rawval.(string)
-			if !ok {
-				return fmt.Errorf("failed to parse parameter %q as string", k)
+	contents, err := json.Marshal(rawval)
+	if err != nil {
+		return "", err
+	}
+
+	// In order to preserve the exact string value the user provided
+	// we don't marshal string parameters
+	value := string(contents)
+	if value[0] == '"' {
+		str, ok := rawval.(string)
+		if !ok {
+			return "", errors.New("failed to parse value as string")
+		}
+		value = str
+	}
+	return value, nil
+}
+
+// injectPreviousOutputs makes the outputs recorded on the claim available to
+// the invocation image, as they were written by the previous operation.
+func injectPreviousOutputs(c *claim.Claim, files map[string]string) error {
+	for _, outputs := range []map[string]interface{}{c.Outputs, c.WriteOnlyOutputs} {
+		for name, rawval := range outputs {
+			if c.OutputEncodings[name] == claim.OutputEncodingBase64 {
+				encoded, ok := rawval.(string)
+				if !ok {
+					return fmt.Errorf("failed to write previous output %q: base64 output is not a string", name)
+				}
+				decoded, err := base64.StdEncoding.DecodeString(encoded)
+				if err != nil {
+					return fmt.Errorf("failed to write previous output %q: %s", name, err)
+				}
+				rawval = decoded
+			}
+			value, err := formatValue(rawval)
+			if err != nil {
+				return fmt.Errorf("failed to write previous output %q: %s", name, err)
 			}
+			files[path.Join(previousOutputsDir, name)] = value
+		}
+	}
+	return nil
+}
+
+func injectParameters(b *bundle.Bundle, params map[string]interface{}, vars envVars, env, files map[string]string) error {
+	names := make([]string, 0, len(params))
+	for k := range params {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		param, ok := b.Parameters[k]
+		if !ok {
+			continue
+		}
+
+		value, err := formatValue(params[k])
+		if err != nil {
+			return fmt.Errorf("failed to parse parameter %q: %s", k, err)
 		}
 
 		if param.Destination == nil {
 			// env is a CNAB_P_
-			env[fmt.Sprintf("CNAB_P_%s", strings.ToUpper(k))] = value
+			name := paramEnvName(k)
+			if err := vars.add(name, fmt.Sprintf("parameter %q", k)); err != nil {
+				return err
+			}
+			env[name] = value
 			continue
 		}
 		if param.Destination.Path != "" {
 			files[param.Destination.Path] = value
 		}
 		if param.Destination.EnvironmentVariable != "" {
+			if err := vars.add(param.Destination.EnvironmentVariable, fmt.Sprintf("parameter %q", k)); err != nil {
+				return err
+			}
 			env[param.Destination.EnvironmentVariable] = value
 		}
 	}
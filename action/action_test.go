@@ -3,6 +3,9 @@ package action
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"strings"
 	"testing"
 	"time"
@@ -10,11 +13,13 @@ import (
 	"github.com/cnabio/cnab-go/claim"
 	"github.com/cnabio/cnab-go/credentials"
 	"github.com/cnabio/cnab-go/driver"
+	"github.com/cnabio/cnab-go/utils/crud"
 
 	"github.com/cnabio/cnab-go/bundle"
 	"github.com/cnabio/cnab-go/bundle/definition"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type mockDriver struct {
@@ -22,6 +27,8 @@ type mockDriver struct {
 	Operation    *driver.Operation
 	Result       driver.OperationResult
 	Error        error
+	// Logs are written to the operation output stream.
+	Logs string
 }
 
 func (d *mockDriver) Handles(imageType string) bool {
@@ -29,6 +36,9 @@ func (d *mockDriver) Handles(imageType string) bool {
 }
 func (d *mockDriver) Run(op *driver.Operation) (driver.OperationResult, error) {
 	d.Operation = op
+	if d.Logs != "" {
+		fmt.Fprint(op.Out, d.Logs)
+	}
 	return d.Result, d.Error
 }
 
@@ -227,6 +237,69 @@ func TestOpFromClaim(t *testing.T) {
 	is.Nil(op.Out)
 }
 
+func TestOpFromClaim_RuntimeEnvironment(t *testing.T) {
+	c := newClaim()
+	c.Outputs = map[string]interface{}{
+		"some-output": "SOME CONTENT",
+		"structured":  map[string]interface{}{"port": 8080},
+	}
+	c.Parameters = map[string]interface{}{"param-with.dots": "dotted"}
+	c.Bundle.Parameters["param-with.dots"] = bundle.Parameter{Definition: "StringParam"}
+	invocImage := c.Bundle.InvocationImages[0]
+
+	op, err := opFromClaim(claim.ActionUpgrade, stateful, c, invocImage, mockSet)
+	require.NoError(t, err)
+
+	is := assert.New(t)
+	is.Equal("revision", op.Environment["CNAB_REVISION"])
+	is.Equal("dotted", op.Environment["CNAB_P_PARAM_WITH_DOTS"])
+
+	var mounted claim.Claim
+	require.NoError(t, json.Unmarshal([]byte(op.Files["/cnab/claim.json"]), &mounted))
+	is.Equal(c.Name, mounted.Name)
+	is.Equal(c.Revision, mounted.Revision)
+
+	is.Equal("SOME CONTENT", op.Files["/cnab/app/previous-outputs/some-output"])
+	is.Equal(`{"port":8080}`, op.Files["/cnab/app/previous-outputs/structured"])
+}
+
+func TestOpFromClaim_EnvironmentCollisions(t *testing.T) {
+	invocImage := mockBundle().InvocationImages[0]
+
+	t.Run("parameters with the same environment variable", func(t *testing.T) {
+		c := newClaim()
+		c.Parameters = map[string]interface{}{"my-param": "a", "my.param": "b"}
+		c.Bundle.Parameters["my-param"] = bundle.Parameter{Definition: "StringParam"}
+		c.Bundle.Parameters["my.param"] = bundle.Parameter{Definition: "StringParam"}
+
+		_, err := opFromClaim(claim.ActionInstall, stateful, c, invocImage, mockSet)
+		assert.EqualError(t, err, `parameter "my-param" and parameter "my.param" both use the environment variable CNAB_P_MY_PARAM`)
+	})
+
+	t.Run("parameter and credential", func(t *testing.T) {
+		c := newClaim()
+		c.Parameters = map[string]interface{}{"param_secret": "a"}
+		c.Bundle.Parameters["param_secret"] = bundle.Parameter{
+			Definition:  "StringParam",
+			Destination: &bundle.Location{EnvironmentVariable: "SECRET_ONE"},
+		}
+
+		_, err := opFromClaim(claim.ActionInstall, stateful, c, invocImage, mockSet)
+		assert.EqualError(t, err, `credential "secret_one" and parameter "param_secret" both use the environment variable SECRET_ONE`)
+	})
+
+	t.Run("credential and reserved variable", func(t *testing.T) {
+		c := newClaim()
+		c.Bundle.Credentials["secret_three"] = bundle.Credential{
+			Location: bundle.Location{EnvironmentVariable: "CNAB_ACTION"},
+		}
+		creds := credentials.Set{"secret_three": "sneaky"}
+
+		_, err := opFromClaim(claim.ActionInstall, stateful, c, invocImage, creds)
+		assert.EqualError(t, err, `credential "secret_three" cannot use the reserved environment variable CNAB_ACTION`)
+	})
+}
+
 func TestOpFromClaim_NoOutputsOnBundle(t *testing.T) {
 	c := newClaim()
 	c.Bundle = mockBundle()
@@ -250,10 +323,92 @@ func TestOpFromClaim_NoOutputsOnBundle(t *testing.T) {
 	var imgMap map[string]bundle.Image
 	is.NoError(json.Unmarshal([]byte(op.Files["/cnab/app/image-map.json"]), &imgMap))
 	is.Equal(c.Bundle.Images, imgMap)
-	is.Len(op.Parameters, 0)
+	// Parameters that were not given are set to their default
+	is.Equal(map[string]interface{}{"param_one": "one", "param_two": "two", "param_three": "three"}, op.Parameters)
 	is.Nil(op.Out)
 }
 
+func TestOpFromClaim_ParameterDefaults(t *testing.T) {
+	c := newClaim()
+	c.Parameters = nil
+	c.Bundle = mockBundle()
+	c.Bundle.Parameters["param_boolean"] = bundle.Parameter{
+		Definition:  "BooleanParam",
+		Destination: &bundle.Location{EnvironmentVariable: "BOOLEAN"},
+	}
+	c.Bundle.Parameters["param_upgrade"] = bundle.Parameter{
+		Definition: "ParamOne",
+		ApplyTo:    []string{claim.ActionUpgrade},
+	}
+	invocImage := c.Bundle.InvocationImages[0]
+
+	op, err := opFromClaim(claim.ActionInstall, stateful, c, invocImage, mockSet)
+	require.NoError(t, err)
+
+	is := assert.New(t)
+	is.Equal("one", op.Parameters["param_one"])
+	is.Equal("two", op.Environment["PARAM_TWO"])
+	is.Equal("three", op.Files["/param/three"])
+	is.Equal("true", op.Environment["BOOLEAN"])
+	is.NotContains(op.Parameters, "param_upgrade", "parameters that do not apply to the action should not be defaulted")
+	is.Nil(c.Parameters, "the claim should not be modified")
+}
+
+func TestOpFromClaim_ParameterCoercion(t *testing.T) {
+	c := newClaim()
+	c.Parameters = map[string]interface{}{
+		"param_boolean": "false",
+		"param_integer": "42",
+		"param_number":  "4.2",
+	}
+	c.Bundle = mockBundle()
+	c.Bundle.Parameters["param_boolean"] = bundle.Parameter{
+		Definition:  "BooleanParam",
+		Destination: &bundle.Location{EnvironmentVariable: "BOOLEAN"},
+	}
+	c.Bundle.Parameters["param_integer"] = bundle.Parameter{Definition: "IntegerParam"}
+	c.Bundle.Parameters["param_number"] = bundle.Parameter{Definition: "NumberParam"}
+	invocImage := c.Bundle.InvocationImages[0]
+
+	op, err := opFromClaim(claim.ActionInstall, stateful, c, invocImage, mockSet)
+	require.NoError(t, err)
+
+	is := assert.New(t)
+	is.Equal(false, op.Parameters["param_boolean"])
+	is.Equal(42, op.Parameters["param_integer"])
+	is.Equal(4.2, op.Parameters["param_number"])
+	is.Equal("false", op.Environment["BOOLEAN"])
+}
+
+func TestOpFromClaim_InvalidParameter(t *testing.T) {
+	c := newClaim()
+	c.Bundle = mockBundle()
+	c.Bundle.Parameters["param_boolean"] = bundle.Parameter{Definition: "BooleanParam"}
+	invocImage := c.Bundle.InvocationImages[0]
+
+	t.Run("value that cannot be converted", func(t *testing.T) {
+		c.Parameters = map[string]interface{}{"param_boolean": "maybe"}
+		_, err := opFromClaim(claim.ActionInstall, stateful, c, invocImage, mockSet)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid value for parameter "param_boolean"`)
+	})
+
+	t.Run("value of the wrong type", func(t *testing.T) {
+		c.Parameters = map[string]interface{}{"param_object": []interface{}{"a"}}
+		_, err := opFromClaim(claim.ActionInstall, stateful, c, invocImage, mockSet)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid value for parameter "param_object"`)
+	})
+
+	t.Run("driver is not run", func(t *testing.T) {
+		c.Parameters = map[string]interface{}{"param_boolean": "maybe"}
+		d := &flakyDriver{}
+		inst := &Install{Driver: d}
+		assert.Error(t, inst.Run(c, mockSet))
+		assert.Equal(t, 0, d.runs)
+	})
+}
+
 func TestOpFromClaim_NoParameter(t *testing.T) {
 	c := newClaim()
 	c.Bundle = mockBundle()
@@ -377,6 +532,33 @@ func TestOpFromClaim_NotApplicableToAction(t *testing.T) {
 	})
 }
 
+func TestOpFromClaim_CredentialSpecificToAction(t *testing.T) {
+	c := newClaim()
+	c.Bundle = mockBundle()
+	c.Bundle.Credentials["admin"] = bundle.Credential{
+		Location: bundle.Location{EnvironmentVariable: "ADMIN_TOKEN", Path: "/admin/token"},
+		Required: true,
+		ApplyTo:  []string{claim.ActionInstall, claim.ActionUpgrade},
+	}
+	invocImage := c.Bundle.InvocationImages[0]
+
+	t.Run("if credential is required for this action and is missing, error", func(t *testing.T) {
+		_, err := opFromClaim(claim.ActionInstall, stateful, c, invocImage, mockSet)
+		assert.EqualError(t, err, `credential "admin" is missing from the user-supplied credentials`)
+	})
+
+	t.Run("if credential does not apply to this action, succeed without it", func(t *testing.T) {
+		creds := credentials.Set{"admin": "root"}
+		for k, v := range mockSet {
+			creds[k] = v
+		}
+		op, err := opFromClaim(claim.ActionStatus, stateful, c, invocImage, creds)
+		require.NoError(t, err)
+		assert.NotContains(t, op.Environment, "ADMIN_TOKEN", "the credential should not be injected")
+		assert.NotContains(t, op.Files, "/admin/token", "the credential should not be injected")
+	})
+}
+
 func TestSetOutputsOnClaim(t *testing.T) {
 	c := newClaim()
 	c.Bundle = mockBundle()
@@ -385,7 +567,7 @@ func TestSetOutputsOnClaim(t *testing.T) {
 		output := map[string]string{
 			"/tmp/some/path": "a valid output",
 		}
-		outputErrors := setOutputsOnClaim(c, output)
+		outputErrors := setOutputsOnClaim(c, output, DefaultMaxOutputSize)
 		assert.NoError(t, outputErrors)
 	})
 
@@ -393,7 +575,7 @@ func TestSetOutputsOnClaim(t *testing.T) {
 		output := map[string]string{
 			"/tmp/some/path": "2",
 		}
-		outputErrors := setOutputsOnClaim(c, output)
+		outputErrors := setOutputsOnClaim(c, output, DefaultMaxOutputSize)
 		assert.NoError(t, outputErrors)
 	})
 
@@ -407,7 +589,7 @@ func TestSetOutputsOnClaim(t *testing.T) {
 		output := map[string]string{
 			"/tmp/some/path": "null",
 		}
-		outputErrors := setOutputsOnClaim(c, output)
+		outputErrors := setOutputsOnClaim(c, output, DefaultMaxOutputSize)
 		assert.NoError(t, outputErrors)
 	})
 
@@ -418,7 +600,7 @@ func TestSetOutputsOnClaim(t *testing.T) {
 		output := map[string]string{
 			"/tmp/some/path": "true",
 		}
-		outputErrors := setOutputsOnClaim(c, output)
+		outputErrors := setOutputsOnClaim(c, output, DefaultMaxOutputSize)
 		assert.NoError(t, outputErrors)
 	})
 
@@ -429,7 +611,7 @@ func TestSetOutputsOnClaim(t *testing.T) {
 		output := map[string]string{
 			"/tmp/some/path": "{}",
 		}
-		outputErrors := setOutputsOnClaim(c, output)
+		outputErrors := setOutputsOnClaim(c, output, DefaultMaxOutputSize)
 		assert.NoError(t, outputErrors)
 	})
 
@@ -440,7 +622,7 @@ func TestSetOutputsOnClaim(t *testing.T) {
 		output := map[string]string{
 			"/tmp/some/path": "[]",
 		}
-		outputErrors := setOutputsOnClaim(c, output)
+		outputErrors := setOutputsOnClaim(c, output, DefaultMaxOutputSize)
 		assert.NoError(t, outputErrors)
 	})
 
@@ -451,7 +633,7 @@ func TestSetOutputsOnClaim(t *testing.T) {
 		output := map[string]string{
 			"/tmp/some/path": "3.14",
 		}
-		outputErrors := setOutputsOnClaim(c, output)
+		outputErrors := setOutputsOnClaim(c, output, DefaultMaxOutputSize)
 		assert.NoError(t, outputErrors)
 	})
 
@@ -462,7 +644,7 @@ func TestSetOutputsOnClaim(t *testing.T) {
 		output := map[string]string{
 			"/tmp/some/path": "372",
 		}
-		outputErrors := setOutputsOnClaim(c, output)
+		outputErrors := setOutputsOnClaim(c, output, DefaultMaxOutputSize)
 		assert.NoError(t, outputErrors)
 	})
 
@@ -473,11 +655,121 @@ func TestSetOutputsOnClaim(t *testing.T) {
 		output := map[string]string{
 			"/tmp/some/path": "372",
 		}
-		outputErrors := setOutputsOnClaim(c, output)
+		outputErrors := setOutputsOnClaim(c, output, DefaultMaxOutputSize)
 		assert.NoError(t, outputErrors)
 	})
 }
 
+func TestSetOutputsOnClaim_TypedValues(t *testing.T) {
+	c := newClaim()
+	c.Bundle = mockBundle()
+	setDefinition := func(def string) {
+		o := c.Bundle.Outputs["some-output"]
+		o.Definition = def
+		c.Bundle.Outputs["some-output"] = o
+	}
+
+	testcases := []struct {
+		definition string
+		content    string
+		want       interface{}
+	}{
+		{"ParamOne", "2", "2"},
+		{"IntegerParam", "372", 372},
+		{"NumberParam", "3.14", 3.14},
+		{"BooleanParam", "true", true},
+		{"ObjectParam", `{"port":8080}`, map[string]interface{}{"port": float64(8080)}},
+		{"ArrayParam", `["a"]`, []interface{}{"a"}},
+		{"BooleanAndIntegerParam", "5", 5},
+		{"StringAndBooleanParam", "false", false},
+		{"StringAndBooleanParam", "XYZ is not a JSON value", "XYZ is not a JSON value"},
+		{"StringParam", "\xff\xfe", "//4="},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.definition+" "+tc.content, func(t *testing.T) {
+			setDefinition(tc.definition)
+			require.NoError(t, setOutputsOnClaim(c, map[string]string{"/tmp/some/path": tc.content}, DefaultMaxOutputSize))
+			assert.Equal(t, tc.want, c.Outputs["some-output"])
+		})
+	}
+
+	t.Run("binary outputs", func(t *testing.T) {
+		setDefinition("StringParam")
+		require.NoError(t, setOutputsOnClaim(c, map[string]string{"/tmp/some/path": "\xff\xfe"}, DefaultMaxOutputSize))
+		assert.Equal(t, "//4=", c.Outputs["some-output"])
+		assert.Equal(t, map[string]string{"some-output": claim.OutputEncodingBase64}, c.OutputEncodings)
+
+		require.NoError(t, setOutputsOnClaim(c, map[string]string{"/tmp/some/path": "text"}, DefaultMaxOutputSize))
+		assert.Empty(t, c.OutputEncodings, "the encodings of earlier outputs should not be kept")
+	})
+
+	t.Run("base64 content encoding", func(t *testing.T) {
+		c.Bundle.Definitions["Binary"] = &definition.Schema{Type: "string", ContentEncoding: "base64"}
+		setDefinition("Binary")
+		require.NoError(t, setOutputsOnClaim(c, map[string]string{"/tmp/some/path": "\x00\x01"}, DefaultMaxOutputSize))
+		assert.Equal(t, "AAE=", c.Outputs["some-output"])
+		assert.Equal(t, map[string]string{"some-output": claim.OutputEncodingBase64}, c.OutputEncodings)
+	})
+
+	t.Run("size limit", func(t *testing.T) {
+		setDefinition("StringParam")
+		err := setOutputsOnClaim(c, map[string]string{"/tmp/some/path": "too long"}, 4)
+		assert.EqualError(t, err, `error: ["some-output" is 8 bytes, which exceeds the limit of 4 bytes]`)
+		assert.NotContains(t, c.Outputs, "some-output")
+	})
+
+	t.Run("write-only outputs", func(t *testing.T) {
+		writeOnly := true
+		c.Bundle.Definitions["Password"] = &definition.Schema{Type: "string", WriteOnly: &writeOnly}
+		setDefinition("Password")
+		require.NoError(t, setOutputsOnClaim(c, map[string]string{"/tmp/some/path": "hunter2"}, DefaultMaxOutputSize))
+		assert.Empty(t, c.Outputs)
+		assert.Equal(t, map[string]interface{}{"some-output": "hunter2"}, c.WriteOnlyOutputs)
+	})
+}
+
+func TestBinaryOutputRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cnabgotest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store := claim.NewClaimStore(crud.NewFileSystemStore(dir, "json"))
+
+	testcases := map[string]struct {
+		definition *definition.Schema
+		content    string
+	}{
+		"string definition": {&definition.Schema{Type: "string"}, "\xff\xfe\x00binary"},
+		"base64 content":    {&definition.Schema{Type: "string", ContentEncoding: "base64"}, "\x00\x01"},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			c, err := claim.New("binary")
+			require.NoError(t, err)
+			c.Bundle = mockBundle()
+			c.Bundle.Definitions["Binary"] = tc.definition
+			o := c.Bundle.Outputs["some-output"]
+			o.Definition = "Binary"
+			c.Bundle.Outputs["some-output"] = o
+
+			inst := &Install{Driver: &mockDriver{
+				shouldHandle: true,
+				Result:       driver.OperationResult{Outputs: map[string]string{"/tmp/some/path": tc.content}},
+			}}
+			require.NoError(t, inst.Run(c, mockSet, func(op *driver.Operation) error {
+				op.Out = ioutil.Discard
+				return nil
+			}))
+			require.NoError(t, store.Save(*c))
+
+			stored, err := store.Read("binary")
+			require.NoError(t, err)
+			op, err := opFromClaim(claim.ActionUpgrade, stateful, &stored, stored.Bundle.InvocationImages[0], mockSet)
+			require.NoError(t, err)
+			assert.Equal(t, tc.content, op.Files["/cnab/app/previous-outputs/some-output"], "the previous output should be given back as it was written")
+		})
+	}
+}
+
 func TestSetOutputsOnClaim_MultipleTypes(t *testing.T) {
 	c := newClaim()
 	c.Bundle = mockBundle()
@@ -490,7 +782,7 @@ func TestSetOutputsOnClaim_MultipleTypes(t *testing.T) {
 			"/tmp/some/path": "false",
 		}
 
-		outputErrors := setOutputsOnClaim(c, output)
+		outputErrors := setOutputsOnClaim(c, output, DefaultMaxOutputSize)
 		assert.NoError(t, outputErrors)
 	})
 
@@ -499,7 +791,7 @@ func TestSetOutputsOnClaim_MultipleTypes(t *testing.T) {
 			"/tmp/some/path": "5",
 		}
 
-		outputErrors := setOutputsOnClaim(c, output)
+		outputErrors := setOutputsOnClaim(c, output, DefaultMaxOutputSize)
 		assert.NoError(t, outputErrors)
 	})
 }
@@ -516,7 +808,7 @@ func TestSetOutputsOnClaim_MultipleTypesWithString(t *testing.T) {
 		output := map[string]string{
 			"/tmp/some/path": "null",
 		}
-		outputErrors := setOutputsOnClaim(c, output)
+		outputErrors := setOutputsOnClaim(c, output, DefaultMaxOutputSize)
 		assert.NoError(t, outputErrors)
 	})
 
@@ -524,7 +816,7 @@ func TestSetOutputsOnClaim_MultipleTypesWithString(t *testing.T) {
 		output := map[string]string{
 			"/tmp/some/path": "XYZ is not a JSON value",
 		}
-		outputErrors := setOutputsOnClaim(c, output)
+		outputErrors := setOutputsOnClaim(c, output, DefaultMaxOutputSize)
 		assert.NoError(t, outputErrors)
 	})
 }
@@ -542,7 +834,7 @@ func TestSetOutputsOnClaim_MismatchType(t *testing.T) {
 			"/tmp/some/path": "2",
 		}
 
-		outputErrors := setOutputsOnClaim(c, invalidParsableOutput)
+		outputErrors := setOutputsOnClaim(c, invalidParsableOutput, DefaultMaxOutputSize)
 		assert.EqualError(t, outputErrors, `error: ["some-output" is not any of the expected types (boolean) because it is "integer"]`)
 	})
 
@@ -551,7 +843,7 @@ func TestSetOutputsOnClaim_MismatchType(t *testing.T) {
 			"/tmp/some/path": "Not a boolean",
 		}
 
-		outputErrors := setOutputsOnClaim(c, invalidNonParsableOutput)
+		outputErrors := setOutputsOnClaim(c, invalidNonParsableOutput, DefaultMaxOutputSize)
 		assert.EqualError(t, outputErrors, `error: [failed to parse "some-output": invalid character 'N' looking for beginning of value]`)
 	})
 }
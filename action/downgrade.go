@@ -0,0 +1,63 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/cnabio/cnab-go/bundle"
+	"github.com/cnabio/cnab-go/claim"
+	"github.com/cnabio/cnab-go/credentials"
+	"github.com/cnabio/cnab-go/driver"
+)
+
+// ErrNotDowngrade indicates that the target bundle version is not lower than the installed version.
+var ErrNotDowngrade = errors.New("target bundle version is not lower than the installed version")
+
+// Downgrade runs a downgrade action
+type Downgrade struct {
+	Driver driver.Driver
+	// Bundle is the older version of the bundle that the installation is downgraded to.
+	Bundle *bundle.Bundle
+	Options
+}
+
+// Run performs the downgrade steps and updates the Claim
+//
+// The claim must reference the currently installed bundle. It is only switched
+// to the target bundle once the target version has been verified to be lower.
+func (d *Downgrade) Run(c *claim.Claim, creds credentials.Set, opCfgs ...OperationConfigFunc) error {
+	if err := validateDowngrade(c.Bundle, d.Bundle); err != nil {
+		return err
+	}
+	c.Bundle = d.Bundle
+
+	_, err := d.run(d.Driver, claim.ActionDowngrade, stateful, modifying, c, creds, opCfgs)
+	return err
+}
+
+// validateDowngrade checks that the target bundle has a lower semantic version
+// than the installed bundle.
+func validateDowngrade(installed, target *bundle.Bundle) error {
+	if target == nil {
+		return errors.New("no target bundle specified for downgrade")
+	}
+	if installed == nil {
+		return errors.New("claim does not reference an installed bundle")
+	}
+
+	installedVersion, err := semver.NewVersion(installed.Version)
+	if err != nil {
+		return fmt.Errorf("invalid installed bundle version %q: %v", installed.Version, err)
+	}
+	targetVersion, err := semver.NewVersion(target.Version)
+	if err != nil {
+		return fmt.Errorf("invalid target bundle version %q: %v", target.Version, err)
+	}
+
+	if !targetVersion.LessThan(installedVersion) {
+		return fmt.Errorf("cannot downgrade from %s to %s: %w", installed.Version, target.Version, ErrNotDowngrade)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package action
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/cnabio/cnab-go/claim"
+	"github.com/cnabio/cnab-go/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makes sure Downgrade implements Action interface
+var _ Action = &Downgrade{}
+
+func TestDowngrade_Run(t *testing.T) {
+	out := func(op *driver.Operation) error {
+		op.Out = ioutil.Discard
+		return nil
+	}
+
+	olderBundle := func() *Downgrade {
+		b := mockBundle()
+		b.Version = "0.0.9"
+		return &Downgrade{Bundle: b}
+	}
+
+	t.Run("happy-path", func(t *testing.T) {
+		c := newClaim()
+		d := &mockDriver{
+			shouldHandle: true,
+			Result: driver.OperationResult{
+				Outputs: map[string]string{
+					"/tmp/some/path": "SOME CONTENT",
+				},
+			},
+		}
+		dg := olderBundle()
+		dg.Driver = d
+		err := dg.Run(c, mockSet, out)
+		assert.NoError(t, err)
+		assert.Equal(t, "0.0.9", c.Bundle.Version)
+		assert.Equal(t, claim.ActionDowngrade, d.Operation.Action)
+		assert.Equal(t, claim.ActionDowngrade, d.Operation.Environment["CNAB_ACTION"])
+		assert.NotEqual(t, c.Created, c.Modified, "Claim was not updated with modified time stamp during downgrade action")
+		assert.Equal(t, claim.ActionDowngrade, c.Result.Action)
+		assert.Equal(t, claim.StatusSuccess, c.Result.Status)
+		assert.Equal(t, map[string]interface{}{"some-output": "SOME CONTENT"}, c.Outputs)
+	})
+
+	t.Run("configure operation", func(t *testing.T) {
+		c := newClaim()
+		d := &mockDriver{shouldHandle: true}
+		dg := olderBundle()
+		dg.Driver = d
+		addFile := func(op *driver.Operation) error {
+			op.Files["/tmp/another/path"] = "ANOTHER FILE"
+			return nil
+		}
+		require.NoError(t, dg.Run(c, mockSet, out, addFile))
+		assert.Contains(t, d.Operation.Files, "/tmp/another/path")
+	})
+
+	t.Run("error case: target version is not lower", func(t *testing.T) {
+		for _, version := range []string{"0.1.0", "0.2.0"} {
+			c := newClaim()
+			d := &mockDriver{shouldHandle: true}
+			dg := olderBundle()
+			dg.Driver = d
+			dg.Bundle.Version = version
+			err := dg.Run(c, mockSet, out)
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrNotDowngrade))
+			assert.Nil(t, d.Operation, "the driver should not have been called")
+			assert.Equal(t, "0.1.0", c.Bundle.Version, "the claim should still reference the installed bundle")
+			assert.Empty(t, c.Result)
+		}
+	})
+
+	t.Run("error case: invalid target version", func(t *testing.T) {
+		c := newClaim()
+		dg := olderBundle()
+		dg.Driver = &mockDriver{shouldHandle: true}
+		dg.Bundle.Version = "latest"
+		assert.EqualError(t, dg.Run(c, mockSet, out), `invalid target bundle version "latest": Invalid Semantic Version`)
+	})
+
+	t.Run("error case: no target bundle", func(t *testing.T) {
+		c := newClaim()
+		dg := &Downgrade{Driver: &mockDriver{shouldHandle: true}}
+		assert.Error(t, dg.Run(c, mockSet, out))
+	})
+
+	t.Run("error case: driver does handle image", func(t *testing.T) {
+		c := newClaim()
+		dg := olderBundle()
+		dg.Driver = &mockDriver{
+			Error:        errors.New("I always fail"),
+			shouldHandle: true,
+		}
+		err := dg.Run(c, mockSet, out)
+		assert.Error(t, err)
+		assert.NotEmpty(t, c.Result.Message, "Expected error message in claim result message")
+		assert.Equal(t, claim.ActionDowngrade, c.Result.Action)
+		assert.Equal(t, claim.StatusFailure, c.Result.Status)
+		assert.Empty(t, c.Outputs)
+	})
+}
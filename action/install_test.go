@@ -4,6 +4,7 @@ import (
 	"errors"
 	"io/ioutil"
 	"testing"
+	"time"
 
 	"github.com/cnabio/cnab-go/claim"
 	"github.com/cnabio/cnab-go/driver"
@@ -37,6 +38,48 @@ func TestInstall_Run(t *testing.T) {
 		assert.Equal(t, map[string]interface{}{"some-output": "SOME CONTENT"}, c.Outputs)
 	})
 
+	t.Run("operation details are recorded", func(t *testing.T) {
+		c := newClaim()
+		exitCode := 0
+		inst := &Install{Driver: &mockDriver{
+			shouldHandle: true,
+			Result: driver.OperationResult{
+				Driver:      "mock",
+				OperationID: "install-foo-abcde",
+				ImageDigest: "sha256:a1b2c3",
+				ExitCode:    &exitCode,
+			},
+		}}
+		before := time.Now()
+		require.NoError(t, inst.Run(c, mockSet, out))
+
+		res := c.Result
+		assert.Equal(t, "mock", res.Driver)
+		assert.Equal(t, "install-foo-abcde", res.OperationID)
+		assert.Equal(t, "sha256:a1b2c3", res.ImageDigest)
+		require.NotNil(t, res.ExitCode)
+		assert.Equal(t, 0, *res.ExitCode)
+		require.NotNil(t, res.Started)
+		require.NotNil(t, res.Stopped)
+		assert.False(t, res.Started.Before(before))
+		assert.False(t, res.Stopped.Before(*res.Started))
+	})
+
+	t.Run("labels and annotations", func(t *testing.T) {
+		c := newClaim()
+		c.Labels = map[string]string{"team": "platform"}
+		d := &mockDriver{shouldHandle: true}
+		inst := &Install{Driver: d}
+		labels := WithLabels(map[string]string{"env": "prod"})
+		annotations := WithAnnotations(map[string]string{"owner": "alice"})
+		require.NoError(t, inst.Run(c, mockSet, out, labels, annotations))
+
+		assert.Equal(t, map[string]string{"team": "platform", "env": "prod"}, d.Operation.Labels)
+		assert.Equal(t, map[string]string{"owner": "alice"}, d.Operation.Annotations)
+		assert.Equal(t, d.Operation.Labels, c.Labels, "the labels should be recorded on the claim")
+		assert.Equal(t, d.Operation.Annotations, c.Annotations, "the annotations should be recorded on the claim")
+	})
+
 	t.Run("configure operation", func(t *testing.T) {
 		c := newClaim()
 		d := &mockDriver{
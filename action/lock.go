@@ -0,0 +1,57 @@
+package action
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultLockTTL is the duration of the lease on the lock of an installation
+// when Options.LockTTL is not set.
+const DefaultLockTTL = time.Hour
+
+// Lock acquires the lock of the installation with Options.Locks, and returns
+// the function that releases it. Nothing is locked when Options.Locks is not
+// set, or for stateless actions, which have no installation name.
+//
+// Observers are notified with EventStaleLockBroken when an expired lease was
+// broken to acquire the lock. Releasing the lock fails with crud.ErrLockLost
+// when the lease expired and the lock was acquired by another owner meanwhile.
+func (o Options) Lock(installation string) (unlock func() error, err error) {
+	if o.Locks == nil || installation == "" {
+		return func() error { return nil }, nil
+	}
+
+	lease, err := o.Locks.Lock(installation, o.lockOwner(), o.lockTTL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock installation %q: %w", installation, err)
+	}
+	if lease.Stale != nil {
+		o.notify(Event{Type: EventStaleLockBroken, Installation: installation, Lock: lease.Stale})
+	}
+
+	return func() error {
+		if err := o.Locks.Unlock(lease); err != nil {
+			return fmt.Errorf("failed to unlock installation %q: %w", installation, err)
+		}
+		return nil
+	}, nil
+}
+
+func (o Options) lockOwner() string {
+	if o.LockOwner != "" {
+		return o.LockOwner
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s/%d", host, os.Getpid())
+}
+
+func (o Options) lockTTL() time.Duration {
+	if o.LockTTL > 0 {
+		return o.LockTTL
+	}
+	return DefaultLockTTL
+}
@@ -0,0 +1,104 @@
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cnabio/cnab-go/driver"
+	"github.com/cnabio/cnab-go/utils/crud"
+)
+
+func TestLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cnabgotest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	locks := crud.NewFileSystemStore(dir, "json").(crud.Locker)
+	out := func(op *driver.Operation) error {
+		op.Out = ioutil.Discard
+		return nil
+	}
+
+	t.Run("lock is held while the driver runs", func(t *testing.T) {
+		var lockErr error
+		d := driverFunc(func(op *driver.Operation) (driver.OperationResult, error) {
+			_, lockErr = locks.Lock(op.Installation, "someone else", time.Minute)
+			return driver.OperationResult{}, nil
+		})
+		inst := &Install{Driver: d, Options: Options{Locks: locks, LockOwner: "me"}}
+		require.NoError(t, inst.Run(newClaim(), mockSet, out))
+
+		var locked *crud.LockedError
+		require.True(t, errors.As(lockErr, &locked), "expected a LockedError, got %v", lockErr)
+		assert.Equal(t, "me", locked.Lease.Owner)
+
+		lease, err := locks.Lock("name", "someone else", time.Minute)
+		require.NoError(t, err, "the lock should be released once the action completed")
+		require.NoError(t, locks.Unlock(lease))
+	})
+
+	t.Run("concurrent action fails before the driver runs", func(t *testing.T) {
+		lease, err := locks.Lock("name", "someone else", time.Minute)
+		require.NoError(t, err)
+		defer locks.Unlock(lease)
+
+		d := &flakyDriver{}
+		c := newClaim()
+		upgr := &Upgrade{Driver: d, Options: Options{Locks: locks}}
+		err = upgr.Run(c, mockSet, out)
+		var locked *crud.LockedError
+		require.True(t, errors.As(err, &locked), "expected a LockedError, got %v", err)
+		assert.Contains(t, err.Error(), `failed to lock installation "name": "name" is locked by someone else`)
+		assert.Equal(t, 0, d.runs)
+		assert.Empty(t, c.Result.Status)
+	})
+
+	t.Run("read-only actions do not take the lock", func(t *testing.T) {
+		lease, err := locks.Lock("name", "someone else", time.Minute)
+		require.NoError(t, err)
+		defer locks.Unlock(lease)
+
+		d := &flakyDriver{}
+		st := &Status{Driver: d, Options: Options{Locks: locks}}
+		require.NoError(t, st.Run(newClaim(), mockSet, out), "status should run while another action holds the lock")
+		assert.Equal(t, 1, d.runs)
+	})
+
+	t.Run("stale lock is broken and reported", func(t *testing.T) {
+		stale, err := locks.Lock("name", "crashed", time.Minute)
+		require.NoError(t, err)
+		stale.Expires = time.Now().Add(-time.Second)
+		data, err := json.Marshal(stale)
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "locks", "name.lock"), data, 0644))
+
+		var broken []*crud.Lease
+		obs := ObserverFunc(func(e Event) {
+			if e.Type == EventStaleLockBroken {
+				broken = append(broken, e.Lock)
+			}
+		})
+		inst := &Install{Driver: &flakyDriver{}, Options: Options{Locks: locks, Observers: []Observer{obs}}}
+		require.NoError(t, inst.Run(newClaim(), mockSet, out))
+		require.Len(t, broken, 1)
+		assert.Equal(t, "crashed", broken[0].Owner)
+	})
+}
+
+// driverFunc adapts a function to the driver.Driver interface.
+type driverFunc func(op *driver.Operation) (driver.OperationResult, error)
+
+func (f driverFunc) Handles(imageType string) bool {
+	return true
+}
+
+func (f driverFunc) Run(op *driver.Operation) (driver.OperationResult, error) {
+	return f(op)
+}
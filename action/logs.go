@@ -0,0 +1,40 @@
+package action
+
+import (
+	"io"
+	"os"
+
+	"github.com/cnabio/cnab-go/claim"
+	"github.com/cnabio/cnab-go/driver"
+)
+
+// teeLog sends the output of the operation to Options.Logs as well, and
+// returns the revision the log is stored under and the function that
+// completes the log.
+//
+// The log is stored under the revision of the operation, which the
+// installation manager sets to the revision of the underway claim, so that the
+// log of a running operation, or of an operation whose process stopped, can be
+// found from the stored claim. When the operation still has the revision of the
+// previous result, which has a log of its own, a new revision is generated for
+// the log instead. Either way, the final claim links to the log with
+// Result.LogRevision.
+func (o Options) teeLog(op *driver.Operation, previous string) (string, func() error, error) {
+	revision := op.Revision
+	if revision == "" || revision == previous {
+		revision = claim.ULID()
+	}
+	w, err := o.Logs.Create(op.Installation, revision)
+	if err != nil {
+		return "", nil, err
+	}
+
+	out := op.Out
+	if out == nil {
+		// Drivers default to the standard output when none is given.
+		out = os.Stdout
+	}
+	op.Out = io.MultiWriter(out, w)
+
+	return revision, w.Close, nil
+}
@@ -0,0 +1,79 @@
+package action
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cnabio/cnab-go/claim"
+	"github.com/cnabio/cnab-go/driver"
+	"github.com/cnabio/cnab-go/utils/crud"
+)
+
+func TestLogs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cnabgotest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	logs := claim.NewLogStore(crud.NewFileSystemStore(dir, "log"))
+
+	d := driverFunc(func(op *driver.Operation) (driver.OperationResult, error) {
+		fmt.Fprintf(op.Out, "running %s\n", op.Action)
+		return driver.OperationResult{}, nil
+	})
+
+	t.Run("output of modifying actions is persisted", func(t *testing.T) {
+		var out bytes.Buffer
+		c := newClaim()
+		inst := &Install{Driver: d, Options: Options{Logs: &logs}}
+		require.NoError(t, inst.Run(c, mockSet, func(op *driver.Operation) error {
+			op.Out = &out
+			return nil
+		}))
+		assert.Equal(t, "running install\n", out.String(), "the output should still be sent to op.Out")
+
+		require.NotEmpty(t, c.Result.LogRevision, "the claim should link to the log")
+		assert.NotEqual(t, "revision", c.Result.LogRevision, "the log of the previous revision should not be replaced")
+		r, err := logs.Open(c.Name, c.Result.LogRevision)
+		require.NoError(t, err)
+		defer r.Close()
+		data, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, "running install\n", string(data))
+	})
+
+	t.Run("log is stored under the revision of the operation", func(t *testing.T) {
+		c := newClaim()
+		inst := &Install{Driver: d, Options: Options{Logs: &logs}}
+		require.NoError(t, inst.Run(c, mockSet, func(op *driver.Operation) error {
+			op.Out = ioutil.Discard
+			op.Revision = "01E0SCKK9CRVB3GM4PJY4V7GQ2"
+			return nil
+		}))
+		assert.Equal(t, "01E0SCKK9CRVB3GM4PJY4V7GQ2", c.Result.LogRevision)
+
+		r, err := logs.Open(c.Name, "01E0SCKK9CRVB3GM4PJY4V7GQ2")
+		require.NoError(t, err)
+		defer r.Close()
+		data, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, "running install\n", string(data))
+	})
+
+	t.Run("output of read-only actions is not persisted", func(t *testing.T) {
+		c := newClaim()
+		c.Revision = "status-revision"
+		st := &Status{Driver: d, Options: Options{Logs: &logs}}
+		require.NoError(t, st.Run(c, mockSet, func(op *driver.Operation) error {
+			op.Out = ioutil.Discard
+			return nil
+		}))
+
+		_, err := logs.Open(c.Name, c.Revision)
+		assert.Equal(t, claim.ErrLogNotFound, err)
+	})
+}
@@ -0,0 +1,132 @@
+package action
+
+import (
+	"time"
+
+	"github.com/cnabio/cnab-go/bundle"
+	"github.com/cnabio/cnab-go/claim"
+	"github.com/cnabio/cnab-go/driver"
+	"github.com/cnabio/cnab-go/utils/crud"
+)
+
+// EventType identifies the stage of an action described by an Event.
+type EventType string
+
+// Event types emitted while an action runs, in the order in which they occur.
+const (
+	// EventStaleLockBroken is emitted when the lock of the installation was
+	// acquired by breaking an expired lease.
+	EventStaleLockBroken EventType = "stale-lock-broken"
+	// EventInvocationImageSelected is emitted once the driver has picked an invocation image.
+	EventInvocationImageSelected EventType = "invocation-image-selected"
+	// EventOperationBuilt is emitted once the operation is built and all
+	// operation configuration functions were applied.
+	EventOperationBuilt EventType = "operation-built"
+	// EventDriverStarted is emitted right before the operation is handed to the driver.
+	EventDriverStarted EventType = "driver-started"
+	// EventOutputCaptured is emitted for each output recorded on the claim.
+	EventOutputCaptured EventType = "output-captured"
+	// EventClaimUpdated is emitted after the claim result has been updated.
+	EventClaimUpdated EventType = "claim-updated"
+	// EventActionFailed is emitted when the action returns an error.
+	EventActionFailed EventType = "action-failed"
+	// EventActionSucceeded is emitted when the action completes without error.
+	EventActionSucceeded EventType = "action-succeeded"
+)
+
+// Event describes something that happened while an action ran.
+type Event struct {
+	Type         EventType
+	Time         time.Time
+	Action       string
+	Installation string
+	// Image is set from EventInvocationImageSelected onwards.
+	Image *bundle.InvocationImage
+	// Operation is set from EventOperationBuilt onwards.
+	Operation *driver.Operation
+	// Lock is the expired lease that was broken, for EventStaleLockBroken.
+	Lock *crud.Lease
+	// Attempt is the number of the driver run, starting at 1, for EventDriverStarted.
+	Attempt int
+	// OutputName and OutputValue are set for EventOutputCaptured. OutputValue
+	// is nil for write-only outputs.
+	OutputName  string
+	OutputValue interface{}
+	// Claim is the claim the action ran against.
+	Claim *claim.Claim
+	// Error is set for EventActionFailed.
+	Error error
+}
+
+// Observer is notified of the events emitted while an action runs.
+//
+// Observers must not modify the claim or the operation referenced by the event.
+type Observer interface {
+	Notify(Event)
+}
+
+// ObserverFunc adapts a function to the Observer interface.
+type ObserverFunc func(Event)
+
+// Notify calls f(e).
+func (f ObserverFunc) Notify(e Event) {
+	f(e)
+}
+
+// PostRunHook is called after the driver has run the operation and, for actions
+// that modify the installation, after the claim result has been updated.
+//
+// A hook may annotate the result, for example by amending c.Result.Message or
+// c.Custom. Returning an error vetoes a successful run: the claim is marked as
+// failed with the hook's error as message, and the action returns that error.
+type PostRunHook func(c *claim.Claim, opResult driver.OperationResult) error
+
+// Options configures behavior shared by all actions.
+type Options struct {
+	// Observers are notified of each stage of the action.
+	Observers []Observer
+	// PostRunHooks are called, in order, once the driver has run.
+	PostRunHooks []PostRunHook
+	// Retry configures retries of failed driver runs. Retries are disabled when nil.
+	Retry *RetryPolicy
+	// MaxOutputSize is the maximum size, in bytes, of an output file.
+	// DefaultMaxOutputSize is used when it is not set.
+	MaxOutputSize int
+	// Locks, when set, is used to lock the installation while an action that
+	// modifies it runs, so that concurrent actions against the same
+	// installation fail instead of overwriting each other's claim. Read-only
+	// actions, such as Status, do not take the lock.
+	Locks crud.Locker
+	// LockOwner identifies the holder of the locks in error messages. It
+	// defaults to the host name and the process ID.
+	LockOwner string
+	// LockTTL is the duration of the lease on the lock. It should exceed the
+	// longest expected action. DefaultLockTTL is used when it is not set.
+	LockTTL time.Duration
+	// Logs, when set, persists the output of the operations of the actions
+	// that modify the installation, keyed by the revision of the operation.
+	// The claim that records their result links to it with
+	// Result.LogRevision.
+	Logs *claim.LogStore
+}
+
+func (o Options) notify(e Event) {
+	if len(o.Observers) == 0 {
+		return
+	}
+	e.Time = time.Now()
+	for _, obs := range o.Observers {
+		obs.Notify(e)
+	}
+}
+
+// postRun calls every hook, and returns the first error encountered.
+func (o Options) postRun(c *claim.Claim, opResult driver.OperationResult) error {
+	var vetoErr error
+	for _, hook := range o.PostRunHooks {
+		if err := hook(c, opResult); err != nil && vetoErr == nil {
+			vetoErr = err
+		}
+	}
+	return vetoErr
+}
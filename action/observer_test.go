@@ -0,0 +1,192 @@
+package action
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/cnabio/cnab-go/claim"
+	"github.com/cnabio/cnab-go/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingObserver struct {
+	events []Event
+}
+
+func (r *recordingObserver) Notify(e Event) {
+	r.events = append(r.events, e)
+}
+
+func (r *recordingObserver) types() []EventType {
+	types := make([]EventType, len(r.events))
+	for i, e := range r.events {
+		types[i] = e.Type
+	}
+	return types
+}
+
+func TestObservers(t *testing.T) {
+	out := func(op *driver.Operation) error {
+		op.Out = ioutil.Discard
+		return nil
+	}
+
+	t.Run("modifying action", func(t *testing.T) {
+		c := newClaim()
+		obs := &recordingObserver{}
+		inst := &Install{
+			Driver: &mockDriver{
+				shouldHandle: true,
+				Result: driver.OperationResult{
+					Outputs: map[string]string{
+						"/tmp/some/path": "SOME CONTENT",
+					},
+				},
+			},
+			Options: Options{Observers: []Observer{obs}},
+		}
+		require.NoError(t, inst.Run(c, mockSet, out))
+
+		assert.Equal(t, []EventType{
+			EventInvocationImageSelected,
+			EventOperationBuilt,
+			EventDriverStarted,
+			EventOutputCaptured,
+			EventClaimUpdated,
+			EventActionSucceeded,
+		}, obs.types())
+		for _, e := range obs.events {
+			assert.Equal(t, claim.ActionInstall, e.Action)
+			assert.Equal(t, c.Name, e.Installation)
+			assert.False(t, e.Time.IsZero())
+			assert.NotNil(t, e.Image)
+		}
+		assert.Equal(t, "some-output", obs.events[3].OutputName)
+		assert.Equal(t, "SOME CONTENT", obs.events[3].OutputValue)
+		assert.Equal(t, claim.StatusSuccess, obs.events[4].Claim.Result.Status)
+	})
+
+	t.Run("read-only action", func(t *testing.T) {
+		c := newClaim()
+		obs := &recordingObserver{}
+		st := &Status{
+			Driver:  &mockDriver{shouldHandle: true},
+			Options: Options{Observers: []Observer{obs}},
+		}
+		require.NoError(t, st.Run(c, mockSet, out))
+
+		assert.Equal(t, []EventType{
+			EventInvocationImageSelected,
+			EventOperationBuilt,
+			EventDriverStarted,
+			EventActionSucceeded,
+		}, obs.types())
+	})
+
+	t.Run("driver failure", func(t *testing.T) {
+		c := newClaim()
+		obs := &recordingObserver{}
+		inst := &Install{
+			Driver: &mockDriver{
+				shouldHandle: true,
+				Error:        errors.New("I always fail"),
+			},
+			Options: Options{Observers: []Observer{obs}},
+		}
+		require.Error(t, inst.Run(c, mockSet, out))
+
+		last := obs.events[len(obs.events)-1]
+		assert.Equal(t, EventActionFailed, last.Type)
+		assert.EqualError(t, last.Error, "I always fail")
+	})
+
+	t.Run("failure before the driver runs", func(t *testing.T) {
+		c := newClaim()
+		var types []EventType
+		inst := &Install{
+			Driver: &mockDriver{shouldHandle: false},
+			Options: Options{Observers: []Observer{ObserverFunc(func(e Event) {
+				types = append(types, e.Type)
+			})}},
+		}
+		require.Error(t, inst.Run(c, mockSet, out))
+		assert.Equal(t, []EventType{EventActionFailed}, types)
+	})
+}
+
+func TestPostRunHooks(t *testing.T) {
+	out := func(op *driver.Operation) error {
+		op.Out = ioutil.Discard
+		return nil
+	}
+
+	t.Run("annotate", func(t *testing.T) {
+		c := newClaim()
+		inst := &Install{
+			Driver: &mockDriver{shouldHandle: true},
+			Options: Options{PostRunHooks: []PostRunHook{
+				func(c *claim.Claim, _ driver.OperationResult) error {
+					c.Result.Message = "audited"
+					return nil
+				},
+			}},
+		}
+		require.NoError(t, inst.Run(c, mockSet, out))
+		assert.Equal(t, claim.StatusSuccess, c.Result.Status)
+		assert.Equal(t, "audited", c.Result.Message)
+	})
+
+	t.Run("veto", func(t *testing.T) {
+		c := newClaim()
+		var called []string
+		inst := &Upgrade{
+			Driver: &mockDriver{shouldHandle: true},
+			Options: Options{PostRunHooks: []PostRunHook{
+				func(c *claim.Claim, _ driver.OperationResult) error {
+					called = append(called, "first")
+					return errors.New("smoke test failed")
+				},
+				func(c *claim.Claim, _ driver.OperationResult) error {
+					called = append(called, "second")
+					return nil
+				},
+			}},
+		}
+		require.EqualError(t, inst.Run(c, mockSet, out), "smoke test failed")
+		assert.Equal(t, []string{"first", "second"}, called, "every hook should be called")
+		assert.Equal(t, claim.ActionUpgrade, c.Result.Action)
+		assert.Equal(t, claim.StatusFailure, c.Result.Status)
+		assert.Equal(t, "smoke test failed", c.Result.Message)
+	})
+
+	t.Run("driver error takes precedence over veto", func(t *testing.T) {
+		c := newClaim()
+		inst := &Install{
+			Driver: &mockDriver{shouldHandle: true, Error: errors.New("I always fail")},
+			Options: Options{PostRunHooks: []PostRunHook{
+				func(c *claim.Claim, _ driver.OperationResult) error {
+					assert.Equal(t, claim.StatusFailure, c.Result.Status)
+					return errors.New("vetoed")
+				},
+			}},
+		}
+		require.EqualError(t, inst.Run(c, mockSet, out), "I always fail")
+		assert.Equal(t, "I always fail", c.Result.Message)
+	})
+
+	t.Run("veto read-only action", func(t *testing.T) {
+		c := newClaim()
+		st := &Status{
+			Driver: &mockDriver{shouldHandle: true},
+			Options: Options{PostRunHooks: []PostRunHook{
+				func(c *claim.Claim, _ driver.OperationResult) error {
+					return errors.New("unhealthy")
+				},
+			}},
+		}
+		require.EqualError(t, st.Run(c, mockSet, out), "unhealthy")
+		assert.Empty(t, c.Result, "read-only actions should not update the claim")
+	})
+}
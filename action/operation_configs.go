@@ -12,6 +12,40 @@ type OperationConfigFunc func(op *driver.Operation) error
 // unit to an operation.
 type OperationConfigs []OperationConfigFunc
 
+// WithLabels sets labels on the operation, in addition to the labels of the
+// installation. Actions that modify the installation record them on its claim.
+func WithLabels(labels map[string]string) OperationConfigFunc {
+	return func(op *driver.Operation) error {
+		op.Labels = merge(op.Labels, labels)
+		return nil
+	}
+}
+
+// WithAnnotations sets annotations on the operation, in addition to the
+// annotations of the installation. Actions that modify the installation record
+// them on its claim.
+func WithAnnotations(annotations map[string]string) OperationConfigFunc {
+	return func(op *driver.Operation) error {
+		op.Annotations = merge(op.Annotations, annotations)
+		return nil
+	}
+}
+
+// merge returns a copy of base with the values of overrides set on it.
+func merge(base, overrides map[string]string) map[string]string {
+	if len(base) == 0 && len(overrides) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(base)+len(overrides))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
+
 // ApplyConfig safely applies the configuration function to the operation, if
 // defined, and stops immediately upon the first error.
 func (cfgs OperationConfigs) ApplyConfig(op *driver.Operation) error {
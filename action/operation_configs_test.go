@@ -39,6 +39,19 @@ func TestOperationConfigs_ApplyConfig(t *testing.T) {
 		assert.Equal(t, os.Stdout, op.Out, "Changes from the second config function were not persisted")
 	})
 
+	t.Run("labels and annotations are merged", func(t *testing.T) {
+		a := OperationConfigs{
+			WithLabels(map[string]string{"team": "platform", "env": "dev"}),
+			WithLabels(map[string]string{"env": "prod"}),
+			WithAnnotations(map[string]string{"owner": "alice"}),
+		}
+		op := &driver.Operation{Labels: map[string]string{"cost-center": "42"}}
+		err := a.ApplyConfig(op)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"cost-center": "42", "team": "platform", "env": "prod"}, op.Labels)
+		assert.Equal(t, map[string]string{"owner": "alice"}, op.Annotations)
+	})
+
 	t.Run("error is returned immediately", func(t *testing.T) {
 		a := OperationConfigs{
 			func(op *driver.Operation) error {
@@ -0,0 +1,95 @@
+package action
+
+import (
+	"time"
+
+	"github.com/cnabio/cnab-go/claim"
+	"github.com/cnabio/cnab-go/driver"
+)
+
+// RetryPolicy configures how an action retries failed driver runs.
+//
+// By default only failures that the driver reports as happening before the
+// invocation image started (see driver.IsNotStarted) are retried, which keeps
+// retries safe for actions that are not idempotent.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times the driver runs the operation,
+	// including the first attempt. Values lower than 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. It doubles after each
+	// subsequent attempt.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between two attempts. Zero means no cap.
+	MaxBackoff time.Duration
+	// Retryable classifies driver errors as transient. When nil, driver.IsNotStarted is used.
+	Retryable func(error) bool
+
+	// sleep waits between attempts. It is replaced in tests.
+	sleep func(time.Duration)
+}
+
+func (p *RetryPolicy) enabled() bool {
+	return p != nil && p.MaxAttempts > 1
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return driver.IsNotStarted(err)
+}
+
+// backoff returns the delay to wait after the given number of failed attempts.
+func (p *RetryPolicy) backoff(failed int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < failed; i++ {
+		delay *= 2
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	return delay
+}
+
+func (p *RetryPolicy) wait(failed int) {
+	sleep := p.sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+	sleep(p.backoff(failed))
+}
+
+// runDriver runs the operation with the driver, retrying failures according to
+// the retry policy. started is called before each attempt with its number,
+// starting at 1. When retries are enabled, every attempt is returned so that
+// it can be recorded on the claim.
+func (o Options) runDriver(d driver.Driver, op *driver.Operation, started func(attempt int)) (driver.OperationResult, []claim.Attempt, error) {
+	var attempts []claim.Attempt
+	for n := 1; ; n++ {
+		started(n)
+		start := time.Now()
+		opResult, err := d.Run(op)
+		if !o.Retry.enabled() {
+			return opResult, nil, err
+		}
+
+		attempt := claim.Attempt{
+			Started:     start,
+			Stopped:     time.Now(),
+			OperationID: opResult.OperationID,
+			ExitCode:    opResult.ExitCode,
+		}
+		if err != nil {
+			attempt.Message = err.Error()
+		}
+		attempts = append(attempts, attempt)
+
+		if err == nil || n >= o.Retry.MaxAttempts || !o.Retry.retryable(err) {
+			return opResult, attempts, err
+		}
+		o.Retry.wait(n)
+	}
+}
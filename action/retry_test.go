@@ -0,0 +1,141 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/cnabio/cnab-go/claim"
+	"github.com/cnabio/cnab-go/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyDriver returns the given errors, in order, before succeeding.
+type flakyDriver struct {
+	errors []error
+	runs   int
+}
+
+func (d *flakyDriver) Handles(imageType string) bool {
+	return true
+}
+
+func (d *flakyDriver) Run(op *driver.Operation) (driver.OperationResult, error) {
+	d.runs++
+	opID := fmt.Sprintf("run-%d", d.runs)
+	if d.runs <= len(d.errors) {
+		return driver.OperationResult{OperationID: opID}, d.errors[d.runs-1]
+	}
+	return driver.OperationResult{OperationID: opID, Outputs: map[string]string{"/tmp/some/path": "SOME CONTENT"}}, nil
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := &RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	assert.Equal(t, time.Second, p.backoff(1))
+	assert.Equal(t, 2*time.Second, p.backoff(2))
+	assert.Equal(t, 4*time.Second, p.backoff(3))
+	assert.Equal(t, 5*time.Second, p.backoff(4))
+	assert.Equal(t, 5*time.Second, p.backoff(100))
+
+	p.MaxBackoff = 0
+	assert.Equal(t, 8*time.Second, p.backoff(4))
+}
+
+func TestRetry(t *testing.T) {
+	out := func(op *driver.Operation) error {
+		op.Out = ioutil.Discard
+		return nil
+	}
+	transient := driver.NotStarted(errors.New("image pull failed"))
+
+	newPolicy := func(sleeps *[]time.Duration) *RetryPolicy {
+		return &RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Second,
+			sleep: func(d time.Duration) {
+				*sleeps = append(*sleeps, d)
+			},
+		}
+	}
+
+	t.Run("transient failure is retried", func(t *testing.T) {
+		var sleeps []time.Duration
+		var attempts []int
+		c := newClaim()
+		d := &flakyDriver{errors: []error{transient, transient}}
+		inst := &Install{Driver: d, Options: Options{
+			Retry: newPolicy(&sleeps),
+			Observers: []Observer{ObserverFunc(func(e Event) {
+				if e.Type == EventDriverStarted {
+					attempts = append(attempts, e.Attempt)
+				}
+			})},
+		}}
+		require.NoError(t, inst.Run(c, mockSet, out))
+
+		assert.Equal(t, 3, d.runs)
+		assert.Equal(t, []int{1, 2, 3}, attempts)
+		assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, sleeps)
+		assert.Equal(t, claim.StatusSuccess, c.Result.Status)
+		assert.Equal(t, map[string]interface{}{"some-output": "SOME CONTENT"}, c.Outputs)
+		require.Len(t, c.Result.Attempts, 3)
+		assert.Equal(t, "image pull failed", c.Result.Attempts[0].Message)
+		assert.Equal(t, "image pull failed", c.Result.Attempts[1].Message)
+		assert.Empty(t, c.Result.Attempts[2].Message)
+		assert.False(t, c.Result.Attempts[2].Stopped.Before(c.Result.Attempts[2].Started))
+		assert.Equal(t, "run-1", c.Result.Attempts[0].OperationID)
+		assert.Equal(t, "run-3", c.Result.OperationID, "the result should describe the last attempt")
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		var sleeps []time.Duration
+		c := newClaim()
+		d := &flakyDriver{errors: []error{transient, transient, transient, transient}}
+		inst := &Upgrade{Driver: d, Options: Options{Retry: newPolicy(&sleeps)}}
+		require.EqualError(t, inst.Run(c, mockSet, out), "image pull failed")
+
+		assert.Equal(t, 3, d.runs)
+		assert.Equal(t, claim.StatusFailure, c.Result.Status)
+		assert.Len(t, c.Result.Attempts, 3)
+	})
+
+	t.Run("failures after the image started are not retried", func(t *testing.T) {
+		var sleeps []time.Duration
+		c := newClaim()
+		d := &flakyDriver{errors: []error{errors.New("container exit code: 1")}}
+		inst := &Uninstall{Driver: d, Options: Options{Retry: newPolicy(&sleeps)}}
+		require.Error(t, inst.Run(c, mockSet, out))
+
+		assert.Equal(t, 1, d.runs)
+		assert.Empty(t, sleeps)
+		assert.Equal(t, claim.StatusFailure, c.Result.Status)
+		assert.Len(t, c.Result.Attempts, 1)
+	})
+
+	t.Run("custom classifier", func(t *testing.T) {
+		var sleeps []time.Duration
+		c := newClaim()
+		d := &flakyDriver{errors: []error{errors.New("api server unavailable"), transient}}
+		policy := newPolicy(&sleeps)
+		policy.Retryable = func(err error) bool {
+			return err.Error() == "api server unavailable"
+		}
+		inst := &Install{Driver: d, Options: Options{Retry: policy}}
+		require.Error(t, inst.Run(c, mockSet, out))
+
+		assert.Equal(t, 2, d.runs, "only errors matched by the classifier should be retried")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		c := newClaim()
+		d := &flakyDriver{errors: []error{transient}}
+		inst := &Install{Driver: d}
+		require.Error(t, inst.Run(c, mockSet, out))
+
+		assert.Equal(t, 1, d.runs)
+		assert.Empty(t, c.Result.Attempts)
+	})
+}
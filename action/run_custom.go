@@ -1,8 +1,12 @@
 package action
 
 import (
+	"bytes"
 	"errors"
+	"io"
+	"sync"
 
+	"github.com/cnabio/cnab-go/bundle"
 	"github.com/cnabio/cnab-go/claim"
 	"github.com/cnabio/cnab-go/credentials"
 	"github.com/cnabio/cnab-go/driver"
@@ -13,62 +17,129 @@ var (
 	ErrBlockedAction = errors.New("action not allowed")
 	// ErrUndefinedAction indicates that a bundle does not define this action.
 	ErrUndefinedAction = errors.New("action not defined for bundle")
+	// ErrStatefulAction indicates that the action must be run against an installation.
+	ErrStatefulAction = errors.New("action is not stateless")
 )
 
 // RunCustom allows the execution of an arbitrary target in a CNAB bundle.
 type RunCustom struct {
 	Driver driver.Driver
 	Action string
+	Options
 }
 
 // blockedActions is a list of actions that cannot be run as custom.
 //
 // This prevents accidental circumvention of standard behavior.
-var blockedActions = map[string]struct{}{"install": {}, "uninstall": {}, "upgrade": {}}
+var blockedActions = map[string]struct{}{"install": {}, "uninstall": {}, "upgrade": {}, "downgrade": {}}
 
 // Run executes a status action in an image
 func (i *RunCustom) Run(c *claim.Claim, creds credentials.Set, opCfgs ...OperationConfigFunc) error {
+	_, err := i.runAgainstClaim(c, creds, opCfgs)
+	return err
+}
+
+// RunWithResult executes the custom action in an image and returns its result,
+// with the outputs that apply to the action validated against their definitions.
+//
+// Actions that do not modify the installation leave the claim untouched, so this
+// is how the outputs of read-only actions reach the caller. Modifying actions
+// update the claim just like Run. When the invocation image fails, the result is
+// returned along with the error. The result is nil when the action failed before
+// the image was run.
+func (i *RunCustom) RunWithResult(c *claim.Claim, creds credentials.Set, opCfgs ...OperationConfigFunc) (*Result, error) {
+	cfgs, logs := captureLogs(opCfgs)
+	opResult, err := i.runAgainstClaim(c, creds, cfgs)
+	res, err := i.newResult(i.Action, c.Bundle, opResult, err)
+	if res != nil {
+		res.Logs = logs.String()
+	}
+	return res, err
+}
+
+func (i *RunCustom) runAgainstClaim(c *claim.Claim, creds credentials.Set, opCfgs []OperationConfigFunc) (*driver.OperationResult, error) {
 	if _, ok := blockedActions[i.Action]; ok {
-		return ErrBlockedAction
+		return nil, ErrBlockedAction
 	}
 
 	actionDef, ok := c.Bundle.Actions[i.Action]
 	if !ok {
-		return ErrUndefinedAction
+		return nil, ErrUndefinedAction
 	}
 
-	invocImage, err := selectInvocationImage(i.Driver, c)
-	if err != nil {
-		return err
+	// If this action says it does not modify the release, then we don't track
+	// it in the claim. Otherwise, we do.
+	return i.run(i.Driver, i.Action, actionDef.Stateless, actionDef.Modifies, c, creds, opCfgs)
+}
+
+// RunStateless executes a stateless custom action against a bundle that does
+// not need to be installed, such as help or dry-run.
+//
+// No claim is involved: the operation is synthesized from the bundle and the
+// parameters, credentials are not injected, and nothing is tracked. The outputs
+// and the logs of the invocation image are returned to the caller. When the
+// invocation image fails, the result is returned along with the error.
+func (i *RunCustom) RunStateless(b *bundle.Bundle, params map[string]interface{}, opCfgs ...OperationConfigFunc) (*Result, error) {
+	if _, ok := blockedActions[i.Action]; ok {
+		return nil, ErrBlockedAction
 	}
 
-	op, err := opFromClaim(i.Action, actionDef.Stateless, c, invocImage, creds)
-	if err != nil {
-		return err
+	actionDef, ok := b.Actions[i.Action]
+	if !ok {
+		return nil, ErrUndefinedAction
+	}
+	if !actionDef.Stateless {
+		return nil, ErrStatefulAction
 	}
 
-	err = OperationConfigs(opCfgs).ApplyConfig(op)
-	if err != nil {
-		return err
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	c := &claim.Claim{
+		Revision:   claim.ULID(),
+		Bundle:     b,
+		Parameters: params,
 	}
 
-	opResult, err := i.Driver.Run(op)
+	cfgs, logs := captureLogs(opCfgs)
+	opResult, err := i.run(i.Driver, i.Action, actionDef.Stateless, readOnly, c, credentials.Set{}, cfgs)
+	res, err := i.newResult(i.Action, b, opResult, err)
+	if res != nil {
+		res.Logs = logs.String()
+	}
+	return res, err
+}
 
-	// If this action says it does not modify the release, then we don't track
-	// it in the claim. Otherwise, we do.
-	if !actionDef.Modifies {
-		return err
+// captureLogs returns the configuration functions with an additional one that
+// copies the output stream of the operation into the returned buffer.
+func captureLogs(opCfgs []OperationConfigFunc) ([]OperationConfigFunc, *syncBuffer) {
+	logs := &syncBuffer{}
+	capture := func(op *driver.Operation) error {
+		if op.Out == nil {
+			op.Out = logs
+		} else {
+			op.Out = io.MultiWriter(op.Out, logs)
+		}
+		return nil
 	}
+	return append(append([]OperationConfigFunc{}, opCfgs...), capture), logs
+}
 
-	// update outputs in claim even if there were errors so users can see the output files.
-	outputErrors := setOutputsOnClaim(c, opResult.Outputs)
+// syncBuffer is a bytes.Buffer that is safe for concurrent writes, as drivers
+// may copy stdout and stderr from separate goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
 
-	if err != nil {
-		c.Update(i.Action, claim.StatusFailure)
-		c.Result.Message = err.Error()
-		return err
-	}
-	c.Update(i.Action, claim.StatusSuccess)
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
 
-	return outputErrors
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
 }
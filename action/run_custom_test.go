@@ -1,6 +1,7 @@
 package action
 
 import (
+	"bytes"
 	"errors"
 	"io/ioutil"
 	"testing"
@@ -165,3 +166,175 @@ func TestRunCustom(t *testing.T) {
 		assert.Empty(t, c.Outputs)
 	})
 }
+
+func TestRunCustom_RunStateless(t *testing.T) {
+	statelessBundle := func() *bundle.Bundle {
+		b := mockBundle()
+		b.Actions["help"] = bundle.Action{Stateless: true}
+		b.Outputs["usage"] = bundle.Output{
+			Definition: "StringParam",
+			Path:       "/cnab/app/outputs/usage",
+			ApplyTo:    []string{"help"},
+		}
+		return b
+	}
+
+	t.Run("happy-path", func(t *testing.T) {
+		d := &mockDriver{
+			shouldHandle: true,
+			Result: driver.OperationResult{
+				Outputs: map[string]string{
+					"/cnab/app/outputs/usage": "mybun [install|help]",
+				},
+			},
+			Logs: "printing help\n",
+		}
+		rc := &RunCustom{Driver: d, Action: "help"}
+		res, err := rc.RunStateless(statelessBundle(), map[string]interface{}{"param_one": "oneval"})
+		require.NoError(t, err)
+
+		assert.Equal(t, "help", res.Action)
+		assert.Equal(t, claim.StatusSuccess, res.Status)
+		assert.Equal(t, "mybun [install|help]", res.Outputs["usage"])
+		assert.Equal(t, "printing help\n", res.Logs)
+
+		assert.Equal(t, "help", d.Operation.Action)
+		assert.Empty(t, d.Operation.Installation)
+		assert.NotEmpty(t, d.Operation.Revision)
+		assert.Equal(t, "oneval", d.Operation.Environment["CNAB_P_PARAM_ONE"])
+		assert.NotContains(t, d.Operation.Environment, "SECRET_ONE", "credentials should not be injected")
+	})
+
+	t.Run("logs are also written to the configured output", func(t *testing.T) {
+		var out bytes.Buffer
+		rc := &RunCustom{Driver: &mockDriver{shouldHandle: true, Logs: "hello"}, Action: "help"}
+		res, err := rc.RunStateless(statelessBundle(), nil, func(op *driver.Operation) error {
+			op.Out = &out
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "hello", res.Logs)
+		assert.Equal(t, "hello", out.String())
+	})
+
+	t.Run("error case: driver returns error", func(t *testing.T) {
+		rc := &RunCustom{Driver: &mockDriver{shouldHandle: true, Logs: "boom", Error: errors.New("I always fail")}, Action: "help"}
+		res, err := rc.RunStateless(statelessBundle(), nil)
+		require.EqualError(t, err, "I always fail")
+		assert.Equal(t, claim.StatusFailure, res.Status)
+		assert.Equal(t, "boom", res.Logs)
+	})
+
+	t.Run("error case: stateful actions should fail", func(t *testing.T) {
+		rc := &RunCustom{Driver: &mockDriver{shouldHandle: true}, Action: "test"}
+		_, err := rc.RunStateless(statelessBundle(), nil)
+		assert.Equal(t, ErrStatefulAction, err)
+	})
+
+	t.Run("error case: forbidden custom actions should fail", func(t *testing.T) {
+		rc := &RunCustom{Driver: &mockDriver{shouldHandle: true}, Action: "install"}
+		_, err := rc.RunStateless(statelessBundle(), nil)
+		assert.Equal(t, ErrBlockedAction, err)
+	})
+
+	t.Run("error case: unknown actions should fail", func(t *testing.T) {
+		rc := &RunCustom{Driver: &mockDriver{shouldHandle: true}, Action: "explain"}
+		_, err := rc.RunStateless(statelessBundle(), nil)
+		assert.Equal(t, ErrUndefinedAction, err)
+	})
+}
+
+func TestRunCustom_RunWithResult(t *testing.T) {
+	out := func(op *driver.Operation) error {
+		op.Out = ioutil.Discard
+		return nil
+	}
+
+	readOnlyClaim := func() *claim.Claim {
+		c := newClaim()
+		c.Bundle.Actions["show-connection-string"] = bundle.Action{}
+		c.Bundle.Outputs["connection-string"] = bundle.Output{
+			Definition: "StringParam",
+			Path:       "/cnab/app/outputs/connection-string",
+			ApplyTo:    []string{"show-connection-string"},
+		}
+		return c
+	}
+
+	t.Run("outputs of a non-modifying action are returned", func(t *testing.T) {
+		c := readOnlyClaim()
+		before := *c
+		rc := &RunCustom{
+			Driver: &mockDriver{
+				shouldHandle: true,
+				Result: driver.OperationResult{
+					Outputs: map[string]string{
+						"/cnab/app/outputs/connection-string": "postgres://db:5432",
+						"/tmp/some/path":                      "SOME CONTENT",
+					},
+				},
+				Logs: "looking up connection string",
+			},
+			Action: "show-connection-string",
+		}
+		res, err := rc.RunWithResult(c, mockSet, out)
+		require.NoError(t, err)
+		assert.Equal(t, claim.StatusSuccess, res.Status)
+		assert.Equal(t, map[string]interface{}{
+			"connection-string": "postgres://db:5432",
+			"some-output":       "SOME CONTENT",
+		}, res.Outputs)
+		assert.Equal(t, "looking up connection string", res.Logs)
+		assert.Equal(t, before, *c, "the claim should not be modified")
+	})
+
+	t.Run("invalid outputs are reported", func(t *testing.T) {
+		c := readOnlyClaim()
+		o := c.Bundle.Outputs["connection-string"]
+		o.Definition = "BooleanParam"
+		c.Bundle.Outputs["connection-string"] = o
+		rc := &RunCustom{
+			Driver: &mockDriver{
+				shouldHandle: true,
+				Result: driver.OperationResult{
+					Outputs: map[string]string{
+						"/cnab/app/outputs/connection-string": "postgres://db:5432",
+					},
+				},
+			},
+			Action: "show-connection-string",
+		}
+		res, err := rc.RunWithResult(c, mockSet, out)
+		require.Error(t, err)
+		assert.NotNil(t, res)
+		assert.Empty(t, c.Outputs)
+	})
+
+	t.Run("modifying actions update the claim", func(t *testing.T) {
+		c := newClaim()
+		rc := &RunCustom{
+			Driver: &mockDriver{
+				shouldHandle: true,
+				Result: driver.OperationResult{
+					Outputs: map[string]string{
+						"/tmp/some/path": "SOME CONTENT",
+					},
+				},
+			},
+			Action: "test",
+		}
+		res, err := rc.RunWithResult(c, mockSet, out)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"some-output": "SOME CONTENT"}, res.Outputs)
+		assert.Equal(t, map[string]interface{}{"some-output": "SOME CONTENT"}, c.Outputs)
+		assert.Equal(t, claim.StatusSuccess, c.Result.Status)
+	})
+
+	t.Run("error case: unknown actions should fail", func(t *testing.T) {
+		c := newClaim()
+		rc := &RunCustom{Driver: &mockDriver{shouldHandle: true}, Action: "unknown"}
+		res, err := rc.RunWithResult(c, mockSet, out)
+		assert.Equal(t, ErrUndefinedAction, err)
+		assert.Nil(t, res)
+	})
+}
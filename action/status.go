@@ -9,26 +9,23 @@ import (
 // Status runs a status action on a CNAB bundle.
 type Status struct {
 	Driver driver.Driver
+	Options
 }
 
 // Run executes a status action in an image
 func (i *Status) Run(c *claim.Claim, creds credentials.Set, opCfgs ...OperationConfigFunc) error {
-	invocImage, err := selectInvocationImage(i.Driver, c)
-	if err != nil {
-		return err
-	}
-
-	op, err := opFromClaim(claim.ActionStatus, stateful, c, invocImage, creds)
-	if err != nil {
-		return err
-	}
-
-	err = OperationConfigs(opCfgs).ApplyConfig(op)
-	if err != nil {
-		return err
-	}
-
 	// Ignore OperationResult because non-modifying actions don't have outputs to save.
-	_, err = i.Driver.Run(op)
+	_, err := i.run(i.Driver, claim.ActionStatus, stateful, readOnly, c, creds, opCfgs)
 	return err
 }
+
+// RunWithResult executes a status action in an image and returns its result.
+// The claim is not modified.
+//
+// When the invocation image fails, the result is returned along with the error,
+// so that callers can report the status of the installation. The result is nil
+// when the action failed before the image was run.
+func (i *Status) RunWithResult(c *claim.Claim, creds credentials.Set, opCfgs ...OperationConfigFunc) (*Result, error) {
+	opResult, err := i.run(i.Driver, claim.ActionStatus, stateful, readOnly, c, creds, opCfgs)
+	return i.newResult(claim.ActionStatus, c.Bundle, opResult, err)
+}
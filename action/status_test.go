@@ -5,6 +5,8 @@ import (
 	"io/ioutil"
 	"testing"
 
+	"github.com/cnabio/cnab-go/bundle"
+	"github.com/cnabio/cnab-go/claim"
 	"github.com/cnabio/cnab-go/driver"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -83,3 +85,105 @@ func TestStatus_Run(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestStatus_RunWithResult(t *testing.T) {
+	out := func(op *driver.Operation) error {
+		op.Out = ioutil.Discard
+		return nil
+	}
+
+	newStatusClaim := func() *claim.Claim {
+		c := newClaim()
+		c.Bundle.Outputs["install-only"] = bundle.Output{
+			Definition: "StringParam",
+			Path:       "/tmp/install/only",
+			ApplyTo:    []string{claim.ActionInstall},
+		}
+		c.Bundle.Outputs["replicas"] = bundle.Output{
+			Definition: "IntegerParam",
+			Path:       "/tmp/replicas",
+			ApplyTo:    []string{claim.ActionStatus},
+		}
+		return c
+	}
+
+	t.Run("happy-path", func(t *testing.T) {
+		c := newStatusClaim()
+		before := *c
+		st := &Status{Driver: &mockDriver{
+			shouldHandle: true,
+			Result: driver.OperationResult{
+				Outputs: map[string]string{
+					"/tmp/some/path":      "SOME CONTENT",
+					"/tmp/install/only":   "IGNORED",
+					"/tmp/replicas":       "3",
+					"/tmp/unknown/output": "IGNORED",
+				},
+			},
+		}}
+		res, err := st.RunWithResult(c, mockSet, out)
+		require.NoError(t, err)
+		assert.Equal(t, claim.ActionStatus, res.Action)
+		assert.Equal(t, claim.StatusSuccess, res.Status)
+		assert.Empty(t, res.Message)
+		assert.Equal(t, map[string]interface{}{
+			"some-output": "SOME CONTENT",
+			"replicas":    3,
+		}, res.Outputs)
+		assert.Equal(t, before, *c, "the claim should not be modified")
+	})
+
+	t.Run("invalid output", func(t *testing.T) {
+		c := newStatusClaim()
+		st := &Status{Driver: &mockDriver{
+			shouldHandle: true,
+			Result: driver.OperationResult{
+				Outputs: map[string]string{
+					"/tmp/replicas": "three",
+				},
+			},
+		}}
+		res, err := st.RunWithResult(c, mockSet, out)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `failed to parse "replicas"`)
+		require.NotNil(t, res)
+		assert.Equal(t, claim.StatusSuccess, res.Status)
+	})
+
+	t.Run("error case: driver returns error", func(t *testing.T) {
+		c := newStatusClaim()
+		exitCode := 3
+		st := &Status{Driver: &mockDriver{
+			shouldHandle: true,
+			Result: driver.OperationResult{
+				Outputs: map[string]string{
+					"/tmp/replicas": "0",
+				},
+				Driver:      "mock",
+				OperationID: "op-1",
+				ExitCode:    &exitCode,
+			},
+			Error: errors.New("unhealthy"),
+		}}
+		res, err := st.RunWithResult(c, mockSet, out)
+		require.EqualError(t, err, "unhealthy")
+		require.NotNil(t, res)
+		assert.Equal(t, claim.StatusFailure, res.Status)
+		assert.Equal(t, "unhealthy", res.Message)
+		require.NotNil(t, res.ExitCode, "the exit code should tell failure modes apart")
+		assert.Equal(t, 3, *res.ExitCode)
+		assert.Equal(t, "mock", res.Driver)
+		assert.Equal(t, "op-1", res.OperationID)
+		assert.Equal(t, map[string]interface{}{"replicas": 0}, res.Outputs)
+		assert.Empty(t, c.Result)
+		assert.Empty(t, c.Outputs)
+	})
+
+	t.Run("error case: driver doesn't handle image", func(t *testing.T) {
+		c := newStatusClaim()
+		st := &Status{Driver: &mockDriver{shouldHandle: false}}
+		res, err := st.RunWithResult(c, mockSet, out)
+		assert.Error(t, err)
+		assert.Nil(t, res)
+	})
+}
@@ -3,6 +3,21 @@ package bundle
 // Credential represents the definition of a CNAB credential
 type Credential struct {
 	Location    `yaml:",inline"`
-	Description string `json:"description,omitempty" yaml:"description,omitempty"`
-	Required    bool   `json:"required,omitempty" yaml:"required,omitempty"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Required    bool     `json:"required,omitempty" yaml:"required,omitempty"`
+	ApplyTo     []string `json:"applyTo,omitempty" yaml:"applyTo,omitempty"`
+}
+
+// AppliesTo returns a boolean value specifying whether or not
+// the Credential applies to the provided action
+func (credential *Credential) AppliesTo(action string) bool {
+	if len(credential.ApplyTo) == 0 {
+		return true
+	}
+	for _, act := range credential.ApplyTo {
+		if action == act {
+			return true
+		}
+	}
+	return false
 }
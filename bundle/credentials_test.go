@@ -29,6 +29,31 @@ func TestCompleteCredDefinition(t *testing.T) {
 
 }
 
+func TestCredentialApplyTo(t *testing.T) {
+	payload := `{
+		"credentials": {
+			"admin": {
+				"env" : "ADMIN_TOKEN",
+				"applyTo" : ["install", "uninstall"]
+			},
+			"reader": {
+				"env" : "READER_TOKEN"
+			}
+		}
+	}`
+
+	definitions, err := Unmarshal([]byte(payload))
+	require.NoError(t, err, "given credentials payload was valid json")
+
+	admin := definitions.Credentials["admin"]
+	assert.Equal(t, []string{"install", "uninstall"}, admin.ApplyTo)
+	assert.True(t, admin.AppliesTo("install"))
+	assert.False(t, admin.AppliesTo("status"))
+
+	reader := definitions.Credentials["reader"]
+	assert.True(t, reader.AppliesTo("status"), "credentials without applyTo apply to every action")
+}
+
 func TestHandleMultipleCreds(t *testing.T) {
 	payload := `{
 		"credentials": {
@@ -0,0 +1,478 @@
+package claim
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"time"
+)
+
+// ArchiveVersion is the version of the archive format written by Export.
+const ArchiveVersion = "v1"
+
+// archiveManifest is the name of the manifest in an archive.
+const archiveManifest = "manifest.json"
+
+// ErrUnsupportedArchiveVersion is returned when importing an archive written
+// with a version of the archive format that is not supported.
+var ErrUnsupportedArchiveVersion = errors.New("unsupported archive version")
+
+// ErrImportConflict is returned when importing a claim whose history diverges
+// from the claim with the same name in the store, and the conflict policy is
+// ConflictFail.
+var ErrImportConflict = errors.New("conflicting claim")
+
+// ConflictPolicy decides what happens to the claims of an archive whose
+// history diverges from the claim with the same name in the store.
+type ConflictPolicy string
+
+const (
+	// ConflictFail fails the import, before any claim is imported.
+	ConflictFail ConflictPolicy = "fail"
+	// ConflictSkip keeps the claim in the store, and skips the claim of the archive.
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictOverwrite replaces the claim in the store, with all of its
+	// revisions, by the claim of the archive.
+	ConflictOverwrite ConflictPolicy = "overwrite"
+)
+
+// ExportOptions configure what Export includes in the archive.
+type ExportOptions struct {
+	// History includes every revision of the claims, rather than only the
+	// latest one.
+	History bool
+	// Logs, when set, is the store of the operation logs of the exported
+	// revisions to include.
+	Logs *LogStore
+	// WriteOnlyOutputs includes the write-only outputs of the claims, which
+	// are otherwise left out of the archive.
+	WriteOnlyOutputs bool
+}
+
+// ImportOptions configure how Import handles the claims of an archive.
+type ImportOptions struct {
+	// OnConflict is the conflict policy. ConflictFail is used when it is not set.
+	OnConflict ConflictPolicy
+	// Logs, when set, is the store the logs of the archive are imported into.
+	// Logs are not imported when it is not set.
+	Logs *LogStore
+}
+
+// ImportResult reports what Import did with the claims of an archive.
+type ImportResult struct {
+	// Imported are the claims that had revisions imported.
+	Imported []string
+	// Skipped are the claims that conflicted, and were skipped.
+	Skipped []string
+	// Unchanged are the claims whose revisions were all in the store already.
+	Unchanged []string
+}
+
+// manifest describes the content of an archive.
+type manifest struct {
+	ArchiveVersion string          `json:"archiveVersion"`
+	Created        time.Time       `json:"created"`
+	Claims         []archivedClaim `json:"claims"`
+	// Checksums are the SHA-256 digests of every other file of the archive,
+	// by path.
+	Checksums map[string]string `json:"checksums"`
+}
+
+// archivedClaim lists the revisions of a claim in an archive, oldest first,
+// and the revisions whose log is included.
+type archivedClaim struct {
+	Name      string   `json:"name"`
+	Revisions []string `json:"revisions"`
+	Logs      []string `json:"logs,omitempty"`
+}
+
+func archivedRevisionPath(name, revision string) string {
+	return path.Join("claims", name, revision+".json")
+}
+
+func archivedWriteOnlyOutputsPath(name, revision string) string {
+	return path.Join("claims", name, revision+".writeonly.json")
+}
+
+func archivedLogPath(name, revision string) string {
+	return path.Join("logs", name, revision+".log")
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Export writes the claims with the given names to w, as a gzipped tar
+// archive that can be imported into another store with Import.
+func (s Store) Export(w io.Writer, names []string, opts ExportOptions) error {
+	m := manifest{
+		ArchiveVersion: ArchiveVersion,
+		Created:        time.Now(),
+		Checksums:      map[string]string{},
+	}
+	files := map[string][]byte{}
+	add := func(p string, data []byte) {
+		files[p] = data
+		m.Checksums[p] = checksum(data)
+	}
+
+	for _, name := range names {
+		latest, err := s.Read(name)
+		if err != nil {
+			return fmt.Errorf("failed to export claim %q: %w", name, err)
+		}
+		revisions := []string{latest.Revision}
+		if opts.History {
+			if revisions, err = s.Revisions(name); err != nil {
+				return fmt.Errorf("failed to export claim %q: %v", name, err)
+			}
+		}
+
+		archived := archivedClaim{Name: name, Revisions: revisions}
+		for _, rev := range revisions {
+			c, err := s.ReadRevision(name, rev)
+			if err != nil {
+				return fmt.Errorf("failed to export revision %s of claim %q: %v", rev, name, err)
+			}
+			data, err := json.MarshalIndent(c, "", "  ")
+			if err != nil {
+				return err
+			}
+			add(archivedRevisionPath(name, rev), data)
+
+			if opts.WriteOnlyOutputs && len(c.WriteOnlyOutputs) > 0 {
+				data, err := json.Marshal(c.WriteOnlyOutputs)
+				if err != nil {
+					return err
+				}
+				add(archivedWriteOnlyOutputsPath(name, rev), data)
+			}
+
+			if opts.Logs != nil {
+				data, err := opts.Logs.readAll(name, rev)
+				if err == ErrLogNotFound {
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("failed to export the log of revision %s of claim %q: %v", rev, name, err)
+				}
+				add(archivedLogPath(name, rev), data)
+				archived.Logs = append(archived.Logs, rev)
+			}
+		}
+		m.Claims = append(m.Claims, archived)
+	}
+
+	manifestData, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	write := func(p string, data []byte) error {
+		hdr := &tar.Header{Name: p, Mode: 0600, Size: int64(len(data)), ModTime: m.Created}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	// The manifest comes first, so that readers know what to expect.
+	if err := write(archiveManifest, manifestData); err != nil {
+		return err
+	}
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if err := write(p, files[p]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Import reads an archive written by Export, and saves its claims.
+//
+// A claim that is not in the store is imported with all of its revisions. A
+// claim whose history in the archive extends the one in the store has the
+// newer revisions imported, and one whose history in the store already
+// includes the latest revision of the archive is left unchanged. Otherwise
+// the histories diverge, and the conflict policy applies.
+//
+// The checksums of the archive are verified before anything is imported, and
+// so are conflicts when the policy is ConflictFail.
+func (s Store) Import(r io.Reader, opts ImportOptions) (ImportResult, error) {
+	m, files, err := readArchive(r)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	policy := opts.OnConflict
+	if policy == "" {
+		policy = ConflictFail
+	}
+	switch policy {
+	case ConflictFail, ConflictSkip, ConflictOverwrite:
+	default:
+		return ImportResult{}, fmt.Errorf("unsupported conflict policy %q", policy)
+	}
+
+	// Plan every claim before importing any, so that a failure leaves the
+	// store untouched.
+	plans := make([]importPlan, len(m.Claims))
+	for i, archived := range m.Claims {
+		plan, err := s.planImport(archived, files)
+		if err != nil {
+			return ImportResult{}, err
+		}
+		if plan.conflict && policy == ConflictFail {
+			return ImportResult{}, fmt.Errorf("cannot import claim %q, whose history diverges from the claim in the store: %w", archived.Name, ErrImportConflict)
+		}
+		plans[i] = plan
+	}
+
+	var res ImportResult
+	for _, plan := range plans {
+		name := plan.archived.Name
+		switch {
+		case plan.conflict && policy == ConflictSkip:
+			res.Skipped = append(res.Skipped, name)
+			continue
+		case plan.conflict:
+			if err := s.overwrite(name, opts.Logs); err != nil {
+				return res, fmt.Errorf("failed to overwrite claim %q: %v", name, err)
+			}
+			plan.claims = plan.all
+		case len(plan.claims) == 0:
+			res.Unchanged = append(res.Unchanged, name)
+			continue
+		}
+
+		for _, c := range plan.claims {
+			if err := s.Save(c); err != nil {
+				return res, fmt.Errorf("failed to import revision %s of claim %q: %v", c.Revision, name, err)
+			}
+		}
+		if opts.Logs != nil {
+			if err := importLogs(*opts.Logs, plan, files); err != nil {
+				return res, err
+			}
+		}
+		res.Imported = append(res.Imported, name)
+	}
+	return res, nil
+}
+
+// importPlan is what importing one claim of an archive involves.
+type importPlan struct {
+	archived archivedClaim
+	// all are the revisions of the archive, oldest first.
+	all []Claim
+	// claims are the revisions that are not in the store yet.
+	claims []Claim
+	// conflict is true when the histories diverge.
+	conflict bool
+}
+
+func (s Store) planImport(archived archivedClaim, files map[string][]byte) (importPlan, error) {
+	plan := importPlan{archived: archived}
+	for _, rev := range archived.Revisions {
+		c, err := Parse(files[archivedRevisionPath(archived.Name, rev)])
+		if err != nil {
+			return plan, fmt.Errorf("invalid revision %s of claim %q in archive: %v", rev, archived.Name, err)
+		}
+		if c.Name != archived.Name || c.Revision != rev {
+			return plan, fmt.Errorf("invalid archive: %s holds revision %s of claim %q", archivedRevisionPath(archived.Name, rev), c.Revision, c.Name)
+		}
+		if data, ok := files[archivedWriteOnlyOutputsPath(archived.Name, rev)]; ok {
+			if err := json.Unmarshal(data, &c.WriteOnlyOutputs); err != nil {
+				return plan, fmt.Errorf("invalid write-only outputs of revision %s of claim %q in archive: %v", rev, archived.Name, err)
+			}
+		}
+		plan.all = append(plan.all, c)
+	}
+
+	head, err := s.Read(archived.Name)
+	if err == ErrClaimNotFound {
+		plan.claims = plan.all
+		return plan, nil
+	}
+	if err != nil {
+		return plan, err
+	}
+
+	// Revisions that both have must be identical.
+	stored := map[string]bool{}
+	for _, c := range plan.all {
+		existing, err := s.ReadRevision(c.Name, c.Revision)
+		if err == ErrClaimNotFound {
+			continue
+		}
+		if err != nil {
+			return plan, err
+		}
+		if !sameRevision(existing, c) {
+			plan.conflict = true
+			return plan, nil
+		}
+		stored[c.Revision] = true
+	}
+
+	archiveHead := plan.all[len(plan.all)-1]
+	if stored[archiveHead.Revision] {
+		// The store is up to date, or ahead of the archive.
+		return plan, nil
+	}
+	if !stored[head.Revision] {
+		plan.conflict = true
+		return plan, nil
+	}
+	// The archive extends the history of the store.
+	for i, c := range plan.all {
+		if c.Revision == head.Revision {
+			plan.claims = plan.all[i+1:]
+			break
+		}
+	}
+	return plan, nil
+}
+
+func sameRevision(a, b Claim) bool {
+	ad, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	bd, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(ad, bd)
+}
+
+// overwrite deletes a claim, with its logs, before it is replaced.
+func (s Store) overwrite(name string, logs *LogStore) error {
+	if logs != nil {
+		revisions, err := logs.Revisions(name)
+		if err != nil {
+			return err
+		}
+		for _, rev := range revisions {
+			if err := logs.Delete(name, rev); err != nil {
+				return err
+			}
+		}
+	}
+	return s.Delete(name)
+}
+
+func importLogs(logs LogStore, plan importPlan, files map[string][]byte) error {
+	imported := map[string]bool{}
+	for _, c := range plan.claims {
+		imported[c.Revision] = true
+	}
+	for _, rev := range plan.archived.Logs {
+		if !imported[rev] {
+			continue
+		}
+		w, err := logs.Create(plan.archived.Name, rev)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(files[archivedLogPath(plan.archived.Name, rev)]); err != nil {
+			w.Close()
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("failed to import the log of revision %s of claim %q: %v", rev, plan.archived.Name, err)
+		}
+	}
+	return nil
+}
+
+// readArchive reads the files of an archive, and verifies them against the
+// manifest.
+func readArchive(r io.Reader) (manifest, map[string][]byte, error) {
+	var m manifest
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return m, nil, fmt.Errorf("invalid archive: %v", err)
+	}
+	defer gz.Close()
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return m, nil, fmt.Errorf("invalid archive: %v", err)
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return m, nil, fmt.Errorf("invalid archive: %v", err)
+		}
+		files[hdr.Name] = data
+	}
+
+	data, ok := files[archiveManifest]
+	if !ok {
+		return m, nil, errors.New("invalid archive: the manifest is missing")
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, nil, fmt.Errorf("invalid archive manifest: %v", err)
+	}
+	if m.ArchiveVersion != ArchiveVersion {
+		return m, nil, fmt.Errorf("archive version %s: %w", m.ArchiveVersion, ErrUnsupportedArchiveVersion)
+	}
+	delete(files, archiveManifest)
+
+	for p, data := range files {
+		sum, ok := m.Checksums[p]
+		if !ok {
+			return m, nil, fmt.Errorf("invalid archive: %s is not listed in the manifest", p)
+		}
+		if checksum(data) != sum {
+			return m, nil, fmt.Errorf("invalid archive: the checksum of %s does not match the manifest", p)
+		}
+	}
+	for p := range m.Checksums {
+		if _, ok := files[p]; !ok {
+			return m, nil, fmt.Errorf("invalid archive: %s is missing", p)
+		}
+	}
+
+	for _, archived := range m.Claims {
+		if !ValidName.MatchString(archived.Name) {
+			return m, nil, fmt.Errorf("invalid archive: invalid claim name %q", archived.Name)
+		}
+		if len(archived.Revisions) == 0 {
+			return m, nil, fmt.Errorf("invalid archive: claim %q has no revision", archived.Name)
+		}
+		for _, rev := range append(append([]string{}, archived.Revisions...), archived.Logs...) {
+			if !ValidRevision.MatchString(rev) {
+				return m, nil, fmt.Errorf("invalid archive: invalid revision %q of claim %q", rev, archived.Name)
+			}
+		}
+	}
+	return m, files, nil
+}
@@ -0,0 +1,208 @@
+package claim
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cnabio/cnab-go/bundle"
+	"github.com/cnabio/cnab-go/utils/crud"
+)
+
+func newArchiveTestStores(t *testing.T) (Store, LogStore, func()) {
+	dir, err := ioutil.TempDir("", "cnabgotest")
+	require.NoError(t, err)
+	backing := crud.NewFileSystemStore(dir, "json")
+	return NewClaimStore(backing), NewLogStore(backing), func() { os.RemoveAll(dir) }
+}
+
+// saveHistory saves a claim through the given actions, with a log for each
+// revision, and returns its revisions.
+func saveHistory(t *testing.T, store Store, logs LogStore, c *Claim, actions ...string) []string {
+	var revisions []string
+	for _, action := range actions {
+		c.Update(action, StatusSuccess)
+		require.NoError(t, store.Save(*c))
+		w, err := logs.Create(c.Name, c.Revision)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(action + " log"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		revisions = append(revisions, c.Revision)
+	}
+	return revisions
+}
+
+func newArchivedClaim(t *testing.T, name string) *Claim {
+	c, err := New(name)
+	require.NoError(t, err)
+	c.Bundle = &bundle.Bundle{Name: "mybun", Version: "0.1.0"}
+	c.WriteOnlyOutputs = map[string]interface{}{"password": "hunter2"}
+	return c
+}
+
+func TestExportImport(t *testing.T) {
+	src, srcLogs, cleanup := newArchiveTestStores(t)
+	defer cleanup()
+	foo := newArchivedClaim(t, "foo")
+	revisions := saveHistory(t, src, srcLogs, foo, ActionInstall, ActionUpgrade)
+
+	t.Run("latest revision only", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, src.Export(&buf, []string{"foo"}, ExportOptions{}))
+
+		dst, dstLogs, cleanup := newArchiveTestStores(t)
+		defer cleanup()
+		res, err := dst.Import(&buf, ImportOptions{Logs: &dstLogs})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"foo"}, res.Imported)
+
+		got, err := dst.Revisions("foo")
+		require.NoError(t, err)
+		assert.Equal(t, revisions[1:], got)
+		c, err := dst.Read("foo")
+		require.NoError(t, err)
+		assert.Empty(t, c.WriteOnlyOutputs, "write-only outputs are not exported by default")
+		_, err = dstLogs.Open("foo", revisions[1])
+		assert.Equal(t, ErrLogNotFound, err)
+	})
+
+	t.Run("history, logs and write-only outputs", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, src.Export(&buf, []string{"foo"}, ExportOptions{History: true, Logs: &srcLogs, WriteOnlyOutputs: true}))
+
+		dst, dstLogs, cleanup := newArchiveTestStores(t)
+		defer cleanup()
+		_, err := dst.Import(&buf, ImportOptions{Logs: &dstLogs})
+		require.NoError(t, err)
+
+		got, err := dst.Revisions("foo")
+		require.NoError(t, err)
+		assert.Equal(t, revisions, got)
+		c, err := dst.Read("foo")
+		require.NoError(t, err)
+		assert.Equal(t, foo.WriteOnlyOutputs, c.WriteOnlyOutputs)
+		assert.Equal(t, "install log", readLog(t, dstLogs, "foo", revisions[0]))
+	})
+
+	t.Run("unknown claim", func(t *testing.T) {
+		err := src.Export(ioutil.Discard, []string{"missing"}, ExportOptions{})
+		assert.True(t, errors.Is(err, ErrClaimNotFound))
+	})
+}
+
+func TestImport_Conflicts(t *testing.T) {
+	src, srcLogs, cleanup := newArchiveTestStores(t)
+	defer cleanup()
+	foo := newArchivedClaim(t, "foo")
+	saveHistory(t, src, srcLogs, foo, ActionInstall)
+	bar := newArchivedClaim(t, "bar")
+	saveHistory(t, src, srcLogs, bar, ActionInstall)
+
+	export := func() *bytes.Buffer {
+		var buf bytes.Buffer
+		require.NoError(t, src.Export(&buf, []string{"bar", "foo"}, ExportOptions{History: true}))
+		return &buf
+	}
+	base := export()
+
+	// The destination has bar as it was, and foo diverged from the source.
+	dst, dstLogs, cleanup := newArchiveTestStores(t)
+	defer cleanup()
+	_, err := dst.Import(bytes.NewReader(base.Bytes()), ImportOptions{})
+	require.NoError(t, err)
+	diverged, err := dst.Read("foo")
+	require.NoError(t, err)
+	saveHistory(t, dst, dstLogs, &diverged, ActionUpgrade)
+	// The source moves on with both claims.
+	saveHistory(t, src, srcLogs, foo, ActionUninstall)
+	barRevisions := saveHistory(t, src, srcLogs, bar, ActionUpgrade)
+
+	t.Run("fail", func(t *testing.T) {
+		_, err := dst.Import(export(), ImportOptions{OnConflict: ConflictFail})
+		assert.True(t, errors.Is(err, ErrImportConflict))
+		c, err := dst.Read("bar")
+		require.NoError(t, err)
+		assert.Equal(t, ActionInstall, c.Result.Action, "nothing should be imported when the import fails")
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		res, err := dst.Import(export(), ImportOptions{OnConflict: ConflictSkip})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"bar"}, res.Imported)
+		assert.Equal(t, []string{"foo"}, res.Skipped)
+
+		c, err := dst.Read("bar")
+		require.NoError(t, err)
+		assert.Equal(t, barRevisions[0], c.Revision, "the newer revision should be imported")
+		c, err = dst.Read("foo")
+		require.NoError(t, err)
+		assert.Equal(t, diverged.Revision, c.Revision)
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		res, err := dst.Import(export(), ImportOptions{OnConflict: ConflictSkip})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"bar"}, res.Unchanged)
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		res, err := dst.Import(export(), ImportOptions{OnConflict: ConflictOverwrite, Logs: &dstLogs})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"foo"}, res.Imported)
+
+		c, err := dst.Read("foo")
+		require.NoError(t, err)
+		assert.Equal(t, foo.Revision, c.Revision)
+		_, err = dst.ReadRevision("foo", diverged.Revision)
+		assert.Equal(t, ErrClaimNotFound, err, "the diverged revisions should be replaced")
+		_, err = dstLogs.Open("foo", diverged.Revision)
+		assert.Equal(t, ErrLogNotFound, err)
+	})
+}
+
+func TestImport_InvalidArchive(t *testing.T) {
+	src, srcLogs, cleanup := newArchiveTestStores(t)
+	defer cleanup()
+	saveHistory(t, src, srcLogs, newArchivedClaim(t, "foo"), ActionInstall)
+
+	var buf bytes.Buffer
+	require.NoError(t, src.Export(&buf, []string{"foo"}, ExportOptions{}))
+
+	// Tamper with the claim.
+	gz, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	var tampered bytes.Buffer
+	gzw := gzip.NewWriter(&tampered)
+	tw := tar.NewWriter(gzw)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		data, err := ioutil.ReadAll(tr)
+		require.NoError(t, err)
+		if hdr.Name != archiveManifest {
+			data = bytes.Replace(data, []byte("0.1.0"), []byte("0.6.6"), 1)
+		}
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: hdr.Name, Mode: 0600, Size: int64(len(data))}))
+		_, err = tw.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+
+	dst, _, cleanup := newArchiveTestStores(t)
+	defer cleanup()
+	_, err = dst.Import(&tampered, ImportOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match the manifest")
+}
@@ -2,12 +2,9 @@ package claim
 
 import (
 	"fmt"
-	"math/rand"
 	"regexp"
 	"time"
 
-	"github.com/oklog/ulid"
-
 	"github.com/cnabio/cnab-go/bundle"
 )
 
@@ -35,21 +32,50 @@ const (
 // provide the necessary data to upgrade, uninstall, and downgrade
 // a CNAB package.
 type Claim struct {
-	Name       string                 `json:"name"`
-	Revision   string                 `json:"revision"`
-	Created    time.Time              `json:"created"`
-	Modified   time.Time              `json:"modified"`
-	Bundle     *bundle.Bundle         `json:"bundle"`
-	Result     Result                 `json:"result,omitempty"`
-	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	// SchemaVersion is the version of the claim schema the claim was written with.
+	SchemaVersion string `json:"schemaVersion"`
+	// Name is the name of the installation.
+	Name     string         `json:"installation"`
+	Revision string         `json:"revision"`
+	Created  time.Time      `json:"created"`
+	Modified time.Time      `json:"modified"`
+	Bundle   *bundle.Bundle `json:"bundle"`
+	// BundleReference is a canonical reference to the bundle used by the last action.
+	BundleReference string                 `json:"bundleReference,omitempty"`
+	Result          Result                 `json:"result,omitempty"`
+	Parameters      map[string]interface{} `json:"parameters,omitempty"`
 	// Outputs is a map from the names of outputs (defined in the bundle) to the contents of the files.
 	Outputs map[string]interface{} `json:"outputs,omitempty"`
-	Custom  interface{}            `json:"custom,omitempty"`
+	// WriteOnlyOutputs holds the outputs whose definition is write-only. They are
+	// not part of the claim body, and are persisted separately by the Store.
+	WriteOnlyOutputs map[string]interface{} `json:"-"`
+	// OutputEncodings records how the outputs that are not stored as they were
+	// written are encoded, keyed by output name. Binary outputs, and outputs
+	// whose definition has a base64 contentEncoding, are stored as their base64
+	// encoding, with the "base64" encoding.
+	OutputEncodings map[string]string `json:"outputEncodings,omitempty"`
+	// Labels identify the installation, such as its team or environment. Claims
+	// can be queried on them, and they are set on the resources the drivers
+	// run operations with.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations hold other information about the installation. They are not
+	// queryable, and are set on the resources of drivers that support them.
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Custom      interface{}       `json:"custom,omitempty"`
 }
 
+// OutputEncodingBase64 is the encoding of outputs stored as their base64
+// encoding in OutputEncodings.
+const OutputEncodingBase64 = "base64"
+
 // ValidName is a regular expression that indicates whether a name is a valid claim name.
 var ValidName = regexp.MustCompile("^[a-zA-Z0-9._-]+$")
 
+// ValidRevision is a regular expression that indicates whether a revision can
+// be stored. The stores separate the revision from the claim name with a dot,
+// so unlike names, revisions cannot contain one.
+var ValidRevision = regexp.MustCompile("^[a-zA-Z0-9_-]+$")
+
 // New creates a new Claim initialized for an installation operation.
 func New(name string) (*Claim, error) {
 
@@ -59,10 +85,11 @@ func New(name string) (*Claim, error) {
 
 	now := time.Now()
 	return &Claim{
-		Name:     name,
-		Revision: ULID(),
-		Created:  now,
-		Modified: now,
+		SchemaVersion: DefaultSchemaVersion,
+		Name:          name,
+		Revision:      ULID(),
+		Created:       now,
+		Modified:      now,
 		Result: Result{
 			Action: ActionUnknown,
 			Status: StatusUnknown,
@@ -88,11 +115,39 @@ type Result struct {
 	Message string `json:"message"`
 	Action  string `json:"action"`
 	Status  string `json:"status"`
+	// Attempts records each run of the operation when the action was configured to retry.
+	Attempts []Attempt `json:"attempts,omitempty"`
+	// Started and Stopped bound the operation, across all of its attempts.
+	Started *time.Time `json:"started,omitempty"`
+	Stopped *time.Time `json:"stopped,omitempty"`
+	// Driver is the name of the driver that ran the operation.
+	Driver string `json:"driver,omitempty"`
+	// ImageDigest is the digest of the invocation image that was run, when
+	// the driver reported it.
+	ImageDigest string `json:"imageDigest,omitempty"`
+	// ExitCode is the exit code of the invocation image, when the driver
+	// reported it.
+	ExitCode *int `json:"exitCode,omitempty"`
+	// LogRevision is the revision the log of the operation is stored under in
+	// a LogStore, when it was persisted.
+	LogRevision string `json:"logRevision,omitempty"`
+	// OperationID correlates the operation with the resources the driver ran
+	// it with, such as the name of a Kubernetes job or the ID of a Docker
+	// container.
+	OperationID string `json:"operationId,omitempty"`
+	// RestoredRevision is the earlier revision of the claim whose bundle and
+	// parameters were restored by the operation, when it rolled back the
+	// installation.
+	RestoredRevision string `json:"restoredRevision,omitempty"`
 }
 
-// ULID generates a string representation of a ULID.
-func ULID() string {
-	now := time.Now()
-	entropy := rand.New(rand.NewSource(now.UnixNano()))
-	return ulid.MustNew(ulid.Timestamp(now), entropy).String()
+// Attempt records a single run of an operation by a driver.
+type Attempt struct {
+	Started time.Time `json:"started"`
+	Stopped time.Time `json:"stopped"`
+	// Message is the error returned by the driver, if any.
+	Message string `json:"message,omitempty"`
+	// OperationID and ExitCode are reported by the driver, as on Result.
+	OperationID string `json:"operationId,omitempty"`
+	ExitCode    *int   `json:"exitCode,omitempty"`
 }
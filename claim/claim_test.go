@@ -3,13 +3,11 @@ package claim
 import (
 	"encoding/json"
 	"io/ioutil"
-	"net/http"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
-
-	"github.com/qri-io/jsonschema"
+	"github.com/stretchr/testify/require"
 
 	"github.com/cnabio/cnab-go/bundle"
 )
@@ -95,11 +93,12 @@ func TestMarshal_New(t *testing.T) {
 }
 
 var exampleClaim = Claim{
-	Name:     "my_claim",
-	Revision: staticRevision,
-	Created:  staticDate,
-	Modified: staticDate,
-	Bundle:   &exampleBundle,
+	SchemaVersion: DefaultSchemaVersion,
+	Name:          "my_claim",
+	Revision:      staticRevision,
+	Created:       staticDate,
+	Modified:      staticDate,
+	Bundle:        &exampleBundle,
 	Result: Result{
 		Action:  ActionInstall,
 		Message: "result message",
@@ -127,41 +126,30 @@ func TestMarshal_AllFields(t *testing.T) {
 }
 
 func TestClaimSchema(t *testing.T) {
-	t.Skip("this test is currently a work in progress; see issue comment below")
-
-	claimBytes, err := json.Marshal(exampleClaim)
-	assert.NoError(t, err, "failed to json.Marshal the claim")
-
-	url := "https://raw.githubusercontent.com/cnabio/cnab-spec/master/schema/claim.schema.json"
-	req, err := http.NewRequest("GET", url, nil)
-	assert.NoError(t, err, "failed to construct GET request for fetching claim schema")
-	res, err := http.DefaultClient.Do(req)
-	assert.NoError(t, err, "failed to get claim schema")
-
-	defer res.Body.Close()
-	schemaData, err := ioutil.ReadAll(res.Body)
-	assert.NoError(t, err, "failed to read claim schema")
-
-	rs := &jsonschema.RootSchema{}
-	// This currently fails; needs https://github.com/cnabio/cnab-spec/pull/243
-	err = json.Unmarshal(schemaData, rs)
-	assert.NoError(t, err, "failed to json.Unmarshal root claim schema")
-
-	// This currently fails due to https://github.com/cnabio/cnab-spec/issues/241
-	// Thus, since the referenced bundle schema can't be fetched, schema validation is impaired
-	// Alternatively, we could read the qri-o/jsonschema docs to see how we might 'seed' our Validator
-	// with a fetched version of the bundle schema (from GitHub, as above for the claim schema)
-	err = rs.FetchRemoteReferences()
-	assert.NoError(t, err, "failed to fetch remote references declared by claim schema")
-
-	errors, err := rs.ValidateBytes(claimBytes)
-	assert.NoError(t, err, "failed to validate claim")
-
-	if len(errors) > 0 {
-		t.Log("claim validation against the JSON schema failed:")
-		for _, error := range errors {
-			t.Log(error)
-		}
-		t.Fail()
-	}
+	assert.NoError(t, exampleClaim.Validate())
+
+	claim := exampleClaim
+	claim.Result.Status = "done"
+	assert.EqualError(t, claim.Validate(), `claim "my_claim" does not match the claim schema: /result/status: "done" should be one of ["canceled", "failure", "pending", "running", "success", "underway", "unknown"]`)
+}
+
+func TestParse(t *testing.T) {
+	t.Run("current schema", func(t *testing.T) {
+		data, err := ioutil.ReadFile("testdata/claim.allfields.json")
+		require.NoError(t, err)
+		claim, err := Parse(data)
+		require.NoError(t, err)
+		assert.Equal(t, "my_claim", claim.Name)
+		assert.Equal(t, exampleClaim.Result, claim.Result)
+	})
+
+	t.Run("unversioned claims are migrated", func(t *testing.T) {
+		data := []byte(`{"name":"my_claim","revision":"revision","created":"1983-04-18T01:02:03.000000004Z","modified":"1983-04-18T01:02:03.000000004Z","bundle":{"name":"mybun","version":"v0.1.0"},"result":{"message":"","action":"install","status":"success"},"parameters":{"replicas":12345678901234567}}`)
+		claim, err := Parse(data)
+		require.NoError(t, err)
+		assert.Equal(t, DefaultSchemaVersion, claim.SchemaVersion)
+		assert.Equal(t, "my_claim", claim.Name)
+		assert.Equal(t, "revision", claim.Revision)
+		assert.Equal(t, float64(12345678901234567), claim.Parameters["replicas"])
+	})
 }
@@ -1,20 +1,41 @@
 package claim
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/cnabio/cnab-go/utils/crud"
 )
 
-// ItemType is the location in the backing store where claims are persisted.
+// ItemType is the location in the backing store where the latest revision of
+// each claim is persisted.
 const ItemType = "claims"
 
+// RevisionsItemType is the location in the backing store where every revision
+// of the claims is persisted.
+const RevisionsItemType = "claim-revisions"
+
+// WriteOnlyOutputsItemType is the location in the backing store where the
+// write-only outputs of claims are persisted, apart from the claim body.
+const WriteOnlyOutputsItemType = "writeonly-outputs"
+
 // ErrClaimNotFound represents a claim not found in claim storage
 var ErrClaimNotFound = errors.New("Claim does not exist")
 
+// ErrRevisionExists is returned when saving a claim whose revision was already
+// superseded by a newer revision. Superseded revisions are immutable: a claim
+// must be updated, which changes its revision, before it is saved again.
+var ErrRevisionExists = errors.New("Claim revision already exists")
+
 // Store is a persistent store for claims.
+//
+// Every revision of a claim is kept as an immutable record. The latest
+// revision of each claim is also stored under the claim name, so that it can
+// be read without listing the revisions.
 type Store struct {
 	backingStore *crud.BackingStore
 }
@@ -27,36 +48,308 @@ func NewClaimStore(store crud.Store) Store {
 	}
 }
 
+// revisionKey is the key of a revision of a claim. Names may contain dots but
+// revisions may not (see ValidRevision), so keys are parsed from the end.
+func revisionKey(name, revision string) string {
+	return name + "." + revision
+}
+
+func parseRevisionKey(key string) (name, revision string, ok bool) {
+	return splitKey(key)
+}
+
+// splitKey splits a key at its last dot.
+func splitKey(key string) (prefix, last string, ok bool) {
+	i := strings.LastIndex(key, ".")
+	if i < 0 {
+		return "", "", false
+	}
+	return key[:i], key[i+1:], true
+}
+
+func validateRevision(name, revision string) error {
+	if !ValidRevision.MatchString(revision) {
+		return fmt.Errorf("invalid revision %q of claim %q. Revisions must be [a-zA-Z0-9-_]+", revision, name)
+	}
+	return nil
+}
+
 // List lists the names of the stored claims.
 func (s Store) List() ([]string, error) {
 	return s.backingStore.List(ItemType)
 }
 
-// Save a claim. Any previous version of the claim (that is, with the same
-// name) is overwritten.
+// Save a claim. The claim is recorded as a new revision, and becomes the
+// latest revision of the claim with the same name.
+//
+// The latest revision may be saved again to amend it. Saving a revision that
+// was superseded fails with ErrRevisionExists, unless its contents did not
+// change.
+//
+// Claims without a schema version are saved with DefaultSchemaVersion. The
+// claim must match the claim schema.
 func (s Store) Save(claim Claim) error {
-	bytes, err := json.MarshalIndent(claim, "", "  ")
+	if claim.SchemaVersion == "" {
+		claim.SchemaVersion = DefaultSchemaVersion
+	}
+	data, err := json.MarshalIndent(claim, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := validate(claim.Name, data); err != nil {
+		return err
+	}
+	if err := validateRevision(claim.Name, claim.Revision); err != nil {
+		return err
+	}
+
+	latest, err := s.preserveLatest(claim.Name)
+	if err != nil {
+		return err
+	}
+
+	key := revisionKey(claim.Name, claim.Revision)
+	if claim.Revision != latest {
+		existing, err := s.readRecord(RevisionsItemType, key)
+		switch {
+		case err == nil && bytes.Equal(existing, data):
+			// Saving a superseded revision again, unchanged.
+			return nil
+		case err == nil:
+			return fmt.Errorf("cannot save revision %s of claim %q: %w", claim.Revision, claim.Name, ErrRevisionExists)
+		case err != crud.ErrRecordDoesNotExist:
+			return err
+		}
+	}
+
+	if err := s.backingStore.Save(RevisionsItemType, key, data); err != nil {
+		return err
+	}
+	if err := s.backingStore.Save(ItemType, claim.Name, data); err != nil {
+		return err
+	}
+	if err := s.backingStore.SaveIndex(ItemType, claim.Name, index(claim)); err != nil {
+		return err
+	}
+	return s.saveWriteOnlyOutputs(claim)
+}
+
+// preserveLatest records the latest revision of the claim, as stored under the
+// claim name, when it has no revision record. This is the case for claims
+// saved before revisions were kept. It returns the latest revision, or an empty
+// string when the claim was never saved.
+func (s Store) preserveLatest(name string) (string, error) {
+	data, err := s.backingStore.Read(ItemType, name)
+	if err != nil {
+		if err == crud.ErrRecordDoesNotExist {
+			return "", nil
+		}
+		return "", err
+	}
+	latest, err := Parse(data)
+	if err != nil {
+		return "", err
+	}
+
+	key := revisionKey(name, latest.Revision)
+	if _, err := s.backingStore.Read(RevisionsItemType, key); err != crud.ErrRecordDoesNotExist {
+		return latest.Revision, err
+	}
+	if err := s.backingStore.Save(RevisionsItemType, key, data); err != nil {
+		return "", err
+	}
+	return latest.Revision, s.migrateWriteOnlyOutputs(latest)
+}
+
+// Migrate records the latest revision of every claim that has no revision
+// record, so that stores written before revisions were kept have a complete
+// history, rewrites the claims written before the claim schema was versioned
+// with the current schema, and indexes every claim for Query. Migrate is
+// idempotent.
+//
+// Claims are migrated to the current schema as they are read, so that Migrate
+// is only needed for other runtimes to read the store.
+func (s Store) Migrate() error {
+	names, err := s.List()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, err := s.preserveLatest(name); err != nil {
+			return fmt.Errorf("failed to migrate claim %q: %v", name, err)
+		}
+		if err := s.migrateRecord(ItemType, name); err != nil {
+			return fmt.Errorf("failed to migrate claim %q: %v", name, err)
+		}
+		if err := s.reindex(name); err != nil {
+			return fmt.Errorf("failed to index claim %q: %v", name, err)
+		}
+	}
+
+	keys, err := s.backingStore.List(RevisionsItemType)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.migrateRecord(RevisionsItemType, key); err != nil {
+			return fmt.Errorf("failed to migrate claim revision %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+func (s Store) reindex(name string) error {
+	data, err := s.backingStore.Read(ItemType, name)
+	if err != nil {
+		return err
+	}
+	claim, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	return s.backingStore.SaveIndex(ItemType, name, index(claim))
+}
+
+// migrateRecord rewrites a claim record with the current claim schema, when it
+// was written before the schema was versioned.
+func (s Store) migrateRecord(itemType, key string) error {
+	data, err := s.backingStore.Read(itemType, key)
+	if err != nil {
+		return err
+	}
+	if _, migrated, err := migrate(data); err != nil || !migrated {
+		return err
+	}
+	data, err = canonical(data)
+	if err != nil {
+		return err
+	}
+	return s.backingStore.Save(itemType, key, data)
+}
+
+// readRecord reads a claim record, and returns it as it would be saved with
+// the current claim schema.
+func (s Store) readRecord(itemType, key string) ([]byte, error) {
+	data, err := s.backingStore.Read(itemType, key)
+	if err != nil {
+		return nil, err
+	}
+	return canonical(data)
+}
+
+// canonical parses a claim record, and formats it as Save does.
+func canonical(data []byte) ([]byte, error) {
+	claim, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(claim, "", "  ")
+}
+
+// Revisions lists the revisions of the claim with the given name, oldest first.
+func (s Store) Revisions(name string) ([]string, error) {
+	keys, err := s.backingStore.List(RevisionsItemType)
+	if err != nil {
+		return nil, err
+	}
+	var revisions []string
+	for _, key := range keys {
+		if n, rev, ok := parseRevisionKey(key); ok && n == name {
+			revisions = append(revisions, rev)
+		}
+	}
+	// ULIDs sort lexically in the order in which they were generated.
+	sort.Strings(revisions)
+	return revisions, nil
+}
+
+// ReadRevision loads a revision of the claim with the given name.
+func (s Store) ReadRevision(name, revision string) (Claim, error) {
+	data, err := s.backingStore.Read(RevisionsItemType, revisionKey(name, revision))
+	if err != nil {
+		if err == crud.ErrRecordDoesNotExist {
+			return Claim{}, ErrClaimNotFound
+		}
+		return Claim{}, err
+	}
+	claim, err := Parse(data)
+	if err != nil {
+		return Claim{}, err
+	}
+	err = s.readWriteOnlyOutputs(&claim)
+	return claim, err
+}
+
+func (s Store) saveWriteOnlyOutputs(claim Claim) error {
+	key := revisionKey(claim.Name, claim.Revision)
+	if len(claim.WriteOnlyOutputs) == 0 {
+		return s.deleteIfExists(WriteOnlyOutputsItemType, key)
+	}
+	data, err := json.Marshal(claim.WriteOnlyOutputs)
 	if err != nil {
 		return err
 	}
-	return s.backingStore.Save(ItemType, claim.Name, bytes)
+	return s.backingStore.Save(WriteOnlyOutputsItemType, key, data)
 }
 
-// Read loads the claim with the given name from the store.
+func (s Store) readWriteOnlyOutputs(claim *Claim) error {
+	data, err := s.backingStore.Read(WriteOnlyOutputsItemType, revisionKey(claim.Name, claim.Revision))
+	if err != nil {
+		if err == crud.ErrRecordDoesNotExist {
+			return nil
+		}
+		return err
+	}
+	if err := json.Unmarshal(data, &claim.WriteOnlyOutputs); err != nil {
+		return fmt.Errorf("error unmarshaling write-only outputs of claim %q: %v", claim.Name, err)
+	}
+	return nil
+}
+
+// migrateWriteOnlyOutputs moves the write-only outputs stored under the claim
+// name, before revisions were kept, to the latest revision of the claim.
+func (s Store) migrateWriteOnlyOutputs(latest Claim) error {
+	data, err := s.backingStore.Read(WriteOnlyOutputsItemType, latest.Name)
+	if err != nil {
+		if err == crud.ErrRecordDoesNotExist {
+			return nil
+		}
+		return err
+	}
+	if err := s.backingStore.Save(WriteOnlyOutputsItemType, revisionKey(latest.Name, latest.Revision), data); err != nil {
+		return err
+	}
+	return s.backingStore.Delete(WriteOnlyOutputsItemType, latest.Name)
+}
+
+func (s Store) deleteIfExists(itemType, key string) error {
+	if _, err := s.backingStore.Read(itemType, key); err != nil {
+		if err == crud.ErrRecordDoesNotExist {
+			return nil
+		}
+		return err
+	}
+	return s.backingStore.Delete(itemType, key)
+}
+
+// Read loads the latest revision of the claim with the given name from the store.
 func (s Store) Read(name string) (Claim, error) {
-	bytes, err := s.backingStore.Read(ItemType, name)
+	data, err := s.backingStore.Read(ItemType, name)
 	if err != nil {
 		if err == crud.ErrRecordDoesNotExist {
 			return Claim{}, ErrClaimNotFound
 		}
 		return Claim{}, err
 	}
-	claim := Claim{}
-	err = json.Unmarshal(bytes, &claim)
+	claim, err := Parse(data)
+	if err != nil {
+		return Claim{}, err
+	}
+	err = s.readWriteOnlyOutputs(&claim)
 	return claim, err
 }
 
-// ReadAll retrieves all of the claims.
+// ReadAll retrieves the latest revision of all of the claims.
 func (s Store) ReadAll() ([]Claim, error) {
 	results, err := s.backingStore.ReadAll(ItemType)
 	if err != nil {
@@ -64,11 +357,13 @@ func (s Store) ReadAll() ([]Claim, error) {
 	}
 
 	claims := make([]Claim, len(results))
-	for i, bytes := range results {
-		var claim Claim
-		err = json.Unmarshal(bytes, &claim)
+	for i, data := range results {
+		claim, err := Parse(data)
 		if err != nil {
-			return nil, fmt.Errorf("error unmarshaling claim: %v", err)
+			return nil, err
+		}
+		if err := s.readWriteOnlyOutputs(&claim); err != nil {
+			return nil, err
 		}
 		claims[i] = claim
 	}
@@ -76,7 +371,26 @@ func (s Store) ReadAll() ([]Claim, error) {
 	return claims, nil
 }
 
-// Delete deletes a claim from the store.
+// Delete deletes a claim, with all of its revisions, from the store.
 func (s Store) Delete(name string) error {
-	return s.backingStore.Delete(ItemType, name)
+	revisions, err := s.Revisions(name)
+	if err != nil {
+		return err
+	}
+	if err := s.backingStore.Delete(ItemType, name); err != nil {
+		return err
+	}
+	if err := s.backingStore.DeleteIndex(ItemType, name); err != nil {
+		return err
+	}
+	for _, rev := range revisions {
+		key := revisionKey(name, rev)
+		if err := s.backingStore.Delete(RevisionsItemType, key); err != nil {
+			return err
+		}
+		if err := s.deleteIfExists(WriteOnlyOutputsItemType, key); err != nil {
+			return err
+		}
+	}
+	return s.deleteIfExists(WriteOnlyOutputsItemType, name)
 }
@@ -1,6 +1,8 @@
 package claim
 
 import (
+	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -77,6 +79,258 @@ func TestCanUpdate(t *testing.T) {
 	is.NotEqual(rev, c.Revision, "revision did not update")
 }
 
+func TestWriteOnlyOutputs(t *testing.T) {
+	is := assert.New(t)
+	claim, err := New("foo")
+	require.NoError(t, err)
+	claim.Bundle = &bundle.Bundle{Name: "foobundle", Version: "0.1.2"}
+	claim.Outputs = map[string]interface{}{"port": "8080"}
+	claim.WriteOnlyOutputs = map[string]interface{}{"password": "hunter2"}
+
+	tempDir, err := ioutil.TempDir("", "duffletest")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	backing := crud.NewFileSystemStore(tempDir, "json")
+	store := NewClaimStore(backing)
+	require.NoError(t, store.Save(*claim))
+
+	body, err := backing.Read(ItemType, "foo")
+	require.NoError(t, err)
+	is.NotContains(string(body), "hunter2", "write-only outputs should not be stored in the claim body")
+
+	c, err := store.Read("foo")
+	require.NoError(t, err)
+	is.Equal(claim.Outputs, c.Outputs)
+	is.Equal(claim.WriteOnlyOutputs, c.WriteOnlyOutputs)
+
+	claims, err := store.ReadAll()
+	require.NoError(t, err)
+	require.Len(t, claims, 1)
+	is.Equal(claim.WriteOnlyOutputs, claims[0].WriteOnlyOutputs)
+
+	t.Run("outputs are removed when no longer set", func(t *testing.T) {
+		claim.WriteOnlyOutputs = nil
+		require.NoError(t, store.Save(*claim))
+		c, err := store.Read("foo")
+		require.NoError(t, err)
+		is.Empty(c.WriteOnlyOutputs)
+	})
+
+	t.Run("outputs are deleted with the claim", func(t *testing.T) {
+		claim.WriteOnlyOutputs = map[string]interface{}{"password": "hunter2"}
+		require.NoError(t, store.Save(*claim))
+		require.NoError(t, store.Delete("foo"))
+		_, err := backing.Read(WriteOnlyOutputsItemType, "foo."+claim.Revision)
+		is.Equal(crud.ErrRecordDoesNotExist, err)
+	})
+}
+
+func TestRevisions(t *testing.T) {
+	is := assert.New(t)
+	tempDir, err := ioutil.TempDir("", "cnabgotest")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	store := NewClaimStore(crud.NewFileSystemStore(tempDir, "json"))
+
+	claim, err := New("foo")
+	require.NoError(t, err)
+	claim.Bundle = &bundle.Bundle{Name: "foobundle", Version: "0.1.0"}
+	var revisions []string
+	for _, action := range []string{ActionInstall, ActionUpgrade, ActionUninstall} {
+		time.Sleep(1 * time.Millisecond)
+		claim.Update(action, StatusSuccess)
+		require.NoError(t, store.Save(*claim))
+		revisions = append(revisions, claim.Revision)
+	}
+
+	other, err := New("foo.bar")
+	require.NoError(t, err)
+	other.Bundle = claim.Bundle
+	require.NoError(t, store.Save(*other))
+
+	t.Run("list in order", func(t *testing.T) {
+		got, err := store.Revisions("foo")
+		require.NoError(t, err)
+		is.Equal(revisions, got)
+	})
+
+	t.Run("read any revision", func(t *testing.T) {
+		c, err := store.ReadRevision("foo", revisions[1])
+		require.NoError(t, err)
+		is.Equal(ActionUpgrade, c.Result.Action)
+
+		_, err = store.ReadRevision("foo", "unknown")
+		is.Equal(ErrClaimNotFound, err)
+	})
+
+	t.Run("read latest", func(t *testing.T) {
+		c, err := store.Read("foo")
+		require.NoError(t, err)
+		is.Equal(revisions[2], c.Revision)
+	})
+
+	t.Run("superseded revisions are immutable", func(t *testing.T) {
+		old, err := store.ReadRevision("foo", revisions[0])
+		require.NoError(t, err)
+		require.NoError(t, store.Save(old), "saving an unchanged revision is a no-op")
+
+		old.Result.Message = "rewriting history"
+		err = store.Save(old)
+		is.True(errors.Is(err, ErrRevisionExists))
+
+		c, err := store.Read("foo")
+		require.NoError(t, err)
+		is.Equal(revisions[2], c.Revision, "the latest revision should not change")
+	})
+
+	t.Run("revisions with a dot are rejected", func(t *testing.T) {
+		// Its key would be read back as a revision of "foo.bar".
+		dotted := *claim
+		dotted.Revision = "bar.01E0SCKK9CRVB3GM4PJY4V7GQ2"
+		err := store.Save(dotted)
+		is.EqualError(err, `invalid revision "bar.01E0SCKK9CRVB3GM4PJY4V7GQ2" of claim "foo". Revisions must be [a-zA-Z0-9-_]+`)
+
+		got, err := store.Revisions("foo.bar")
+		require.NoError(t, err)
+		is.Equal([]string{other.Revision}, got)
+	})
+
+	t.Run("delete all revisions", func(t *testing.T) {
+		require.NoError(t, store.Delete("foo"))
+		got, err := store.Revisions("foo")
+		require.NoError(t, err)
+		is.Empty(got)
+
+		got, err = store.Revisions("foo.bar")
+		require.NoError(t, err)
+		is.Equal([]string{other.Revision}, got)
+	})
+}
+
+func TestMigrate(t *testing.T) {
+	is := assert.New(t)
+	tempDir, err := ioutil.TempDir("", "cnabgotest")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	backing := crud.NewFileSystemStore(tempDir, "json")
+	store := NewClaimStore(backing)
+
+	// Claims saved before revisions were kept only have a single document,
+	// written before the claim schema was versioned.
+	legacy := func(name string) *Claim {
+		c, err := New(name)
+		require.NoError(t, err)
+		c.Bundle = &bundle.Bundle{Name: "foobundle", Version: "0.1.0"}
+		data, err := json.Marshal(c)
+		require.NoError(t, err)
+		var doc map[string]interface{}
+		require.NoError(t, json.Unmarshal(data, &doc))
+		doc["name"] = doc["installation"]
+		delete(doc, "installation")
+		delete(doc, "schemaVersion")
+		data, err = json.Marshal(doc)
+		require.NoError(t, err)
+		require.NoError(t, backing.Save(ItemType, name, data))
+		return c
+	}
+
+	t.Run("explicit migration", func(t *testing.T) {
+		c := legacy("foo")
+		require.NoError(t, store.Migrate())
+		require.NoError(t, store.Migrate(), "migrating twice should be a no-op")
+		revisions, err := store.Revisions("foo")
+		require.NoError(t, err)
+		is.Equal([]string{c.Revision}, revisions)
+
+		for _, data := range [][]byte{
+			mustRead(t, backing, ItemType, "foo"),
+			mustRead(t, backing, RevisionsItemType, "foo."+c.Revision),
+		} {
+			is.Contains(string(data), `"schemaVersion": "v1.0.0-WD"`)
+			is.Contains(string(data), `"installation": "foo"`)
+			is.NotContains(string(data), `"name": "foo"`)
+		}
+	})
+
+	t.Run("migration on read", func(t *testing.T) {
+		c := legacy("baz")
+		read, err := store.Read("baz")
+		require.NoError(t, err)
+		is.Equal("baz", read.Name)
+		is.Equal(DefaultSchemaVersion, read.SchemaVersion)
+		is.Equal(c.Revision, read.Revision)
+	})
+
+	t.Run("migration on save", func(t *testing.T) {
+		c := legacy("bar")
+		previous := c.Revision
+		time.Sleep(1 * time.Millisecond)
+		c.Update(ActionUpgrade, StatusSuccess)
+		require.NoError(t, store.Save(*c))
+		revisions, err := store.Revisions("bar")
+		require.NoError(t, err)
+		is.Equal([]string{previous, c.Revision}, revisions)
+	})
+}
+
+func mustRead(t *testing.T, store crud.Store, itemType, name string) []byte {
+	data, err := store.Read(itemType, name)
+	require.NoError(t, err)
+	return data
+}
+
+func TestSchemaValidation(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "cnabgotest")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	backing := crud.NewFileSystemStore(tempDir, "json")
+	store := NewClaimStore(backing)
+
+	t.Run("invalid claims are not saved", func(t *testing.T) {
+		c, err := New("foo")
+		require.NoError(t, err)
+		err = store.Save(*c)
+		assert.EqualError(t, err, `claim "foo" does not match the claim schema: /bundle: type should be object`)
+	})
+
+	t.Run("claims without a schema version are saved with the default", func(t *testing.T) {
+		c := Claim{
+			Name:     "bar",
+			Revision: ULID(),
+			Bundle:   &bundle.Bundle{Name: "barbundle", Version: "0.1.0"},
+			Result:   Result{Action: ActionInstall, Status: StatusSuccess},
+		}
+		require.NoError(t, store.Save(c))
+		read, err := store.Read("bar")
+		require.NoError(t, err)
+		assert.Equal(t, DefaultSchemaVersion, read.SchemaVersion)
+	})
+
+	t.Run("unsupported schema versions are not read", func(t *testing.T) {
+		c, err := New("baz")
+		require.NoError(t, err)
+		c.Bundle = &bundle.Bundle{Name: "bazbundle", Version: "0.1.0"}
+		c.SchemaVersion = "v2.0.0"
+		data, err := json.Marshal(c)
+		require.NoError(t, err)
+		require.NoError(t, backing.Save(ItemType, "baz", data))
+
+		_, err = store.Read("baz")
+		assert.True(t, errors.Is(err, ErrUnsupportedSchemaVersion))
+	})
+
+	t.Run("invalid claims are not read", func(t *testing.T) {
+		data := []byte(`{"schemaVersion": "v1.0.0-WD", "installation": "qux", "result": {"action": "install", "status": "done"}}`)
+		require.NoError(t, backing.Save(ItemType, "qux", data))
+
+		_, err := store.Read("qux")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `claim "qux" does not match the claim schema`)
+		assert.Contains(t, err.Error(), `/result/status`)
+	})
+}
+
 func TestReadAll(t *testing.T) {
 	is := assert.New(t)
 
@@ -120,6 +374,7 @@ func TestCanUpdateOutputs(t *testing.T) {
 	is := assert.New(t)
 	claim, err := New("foo")
 	is.NoError(err)
+	claim.Bundle = &bundle.Bundle{Name: "foobundle", Version: "0.1.0"}
 	is.Equal(map[string]interface{}{}, claim.Outputs)
 
 	tempDir, err := ioutil.TempDir("", "cnabgotest")
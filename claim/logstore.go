@@ -0,0 +1,301 @@
+package claim
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/cnabio/cnab-go/utils/crud"
+)
+
+// LogsItemType is the location in the backing store where operation logs are persisted.
+const LogsItemType = "logs"
+
+// DefaultLogChunkSize is the size, in bytes, of the chunks in which logs are
+// persisted when LogStore.ChunkSize is not set.
+const DefaultLogChunkSize = 64 * 1024
+
+// ErrLogNotFound represents a log not found in log storage
+var ErrLogNotFound = errors.New("Log does not exist")
+
+// logTruncated is appended to logs that exceed LogStore.MaxSize.
+const logTruncated = "\n[log truncated]\n"
+
+// LogStore is a persistent store for the output of operations, keyed by
+// installation name and claim revision.
+//
+// Logs are persisted in chunks as they are written, so that the output of an
+// operation that never completes is still available, and are read back one
+// chunk at a time. The last chunk is saved again on each write until it is
+// complete.
+type LogStore struct {
+	backingStore *crud.BackingStore
+	// MaxRevisions is the number of logs kept per installation. The logs of the
+	// oldest revisions are deleted once a new log is closed. All logs are kept
+	// when it is not set.
+	MaxRevisions int
+	// MaxSize is the size, in bytes, after which a log is truncated. Logs are
+	// not truncated when it is not set.
+	MaxSize int
+	// ChunkSize is the size, in bytes, of the chunks in which logs are
+	// persisted. DefaultLogChunkSize is used when it is not set.
+	ChunkSize int
+}
+
+// NewLogStore creates a persistent store for operation logs using the
+// specified backing key-blob store.
+func NewLogStore(store crud.Store) LogStore {
+	return LogStore{
+		backingStore: crud.NewBackingStore(store),
+	}
+}
+
+// logKey identifies one chunk of the log of a revision. Revisions may not
+// contain dots (see ValidRevision), so keys are parsed from the end.
+type logKey struct {
+	installation string
+	revision     string
+	chunk        int
+}
+
+func (k logKey) String() string {
+	return fmt.Sprintf("%s.%s.%08d", k.installation, k.revision, k.chunk)
+}
+
+func parseLogKey(key string) (logKey, bool) {
+	prefix, chunkStr, ok := splitKey(key)
+	if !ok {
+		return logKey{}, false
+	}
+	chunk, err := strconv.Atoi(chunkStr)
+	if err != nil {
+		return logKey{}, false
+	}
+	installation, revision, ok := parseRevisionKey(prefix)
+	if !ok {
+		return logKey{}, false
+	}
+	return logKey{installation: installation, revision: revision, chunk: chunk}, true
+}
+
+// chunks lists the chunks of the installation's logs, in order.
+func (s LogStore) chunks(installation string) ([]logKey, error) {
+	names, err := s.backingStore.List(LogsItemType)
+	if err != nil {
+		return nil, err
+	}
+	var keys []logKey
+	for _, name := range names {
+		if k, ok := parseLogKey(name); ok && k.installation == installation {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].revision != keys[j].revision {
+			return keys[i].revision < keys[j].revision
+		}
+		return keys[i].chunk < keys[j].chunk
+	})
+	return keys, nil
+}
+
+func (s LogStore) revisionChunks(installation, revision string) ([]logKey, error) {
+	keys, err := s.chunks(installation)
+	if err != nil {
+		return nil, err
+	}
+	var chunks []logKey
+	for _, k := range keys {
+		if k.revision == revision {
+			chunks = append(chunks, k)
+		}
+	}
+	return chunks, nil
+}
+
+// Revisions lists the revisions of the installation that have a log, oldest first.
+func (s LogStore) Revisions(installation string) ([]string, error) {
+	keys, err := s.chunks(installation)
+	if err != nil {
+		return nil, err
+	}
+	var revisions []string
+	for _, k := range keys {
+		if len(revisions) == 0 || revisions[len(revisions)-1] != k.revision {
+			revisions = append(revisions, k.revision)
+		}
+	}
+	return revisions, nil
+}
+
+// Create starts the log of a revision, replacing any existing log for it.
+// The log is complete once the returned writer is closed.
+//
+// Errors persisting the log are not returned by Write, so that the writer can
+// be combined with other writers without interrupting them, but by Close.
+func (s LogStore) Create(installation, revision string) (io.WriteCloser, error) {
+	if err := validateRevision(installation, revision); err != nil {
+		return nil, err
+	}
+	if err := s.Delete(installation, revision); err != nil && err != ErrLogNotFound {
+		return nil, err
+	}
+	return &logWriter{store: s, key: logKey{installation: installation, revision: revision}}, nil
+}
+
+// Open reads the log of a revision.
+func (s LogStore) Open(installation, revision string) (io.ReadCloser, error) {
+	chunks, err := s.revisionChunks(installation, revision)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 {
+		return nil, ErrLogNotFound
+	}
+	return &logReader{store: s, chunks: chunks}, nil
+}
+
+// readAll reads the whole log of a revision.
+func (s LogStore) readAll(installation, revision string) ([]byte, error) {
+	r, err := s.Open(installation, revision)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// Delete deletes the log of a revision.
+func (s LogStore) Delete(installation, revision string) error {
+	chunks, err := s.revisionChunks(installation, revision)
+	if err != nil {
+		return err
+	}
+	if len(chunks) == 0 {
+		return ErrLogNotFound
+	}
+	for _, k := range chunks {
+		if err := s.backingStore.Delete(LogsItemType, k.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prune deletes the logs of the oldest revisions beyond MaxRevisions.
+func (s LogStore) prune(installation string) error {
+	if s.MaxRevisions <= 0 {
+		return nil
+	}
+	revisions, err := s.Revisions(installation)
+	if err != nil {
+		return err
+	}
+	for len(revisions) > s.MaxRevisions {
+		if err := s.Delete(installation, revisions[0]); err != nil {
+			return err
+		}
+		revisions = revisions[1:]
+	}
+	return nil
+}
+
+func (s LogStore) chunkSize() int {
+	if s.ChunkSize > 0 {
+		return s.ChunkSize
+	}
+	return DefaultLogChunkSize
+}
+
+type logWriter struct {
+	mu        sync.Mutex
+	store     LogStore
+	key       logKey
+	buf       bytes.Buffer
+	written   int
+	truncated bool
+	err       error
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.truncated {
+		return len(p), nil
+	}
+	data := p
+	if max := w.store.MaxSize; max > 0 && w.written+len(data) > max {
+		data = data[:max-w.written]
+		w.truncated = true
+	}
+	w.written += len(data)
+	w.buf.Write(data)
+	if w.truncated {
+		w.buf.WriteString(logTruncated)
+	}
+
+	for w.buf.Len() >= w.store.chunkSize() {
+		w.save(w.buf.Next(w.store.chunkSize()))
+		w.key.chunk++
+	}
+	// Persist the partial chunk as well: it is saved again by the next writes
+	// until it is complete.
+	if w.buf.Len() > 0 {
+		w.save(w.buf.Bytes())
+	}
+	return len(p), nil
+}
+
+// save persists the current chunk, and records the first error.
+func (w *logWriter) save(chunk []byte) {
+	if w.err != nil {
+		return
+	}
+	w.err = w.store.backingStore.Save(LogsItemType, w.key.String(), chunk)
+}
+
+func (w *logWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Persist an empty chunk for empty logs, so that they can be found.
+	if w.buf.Len() == 0 && w.key.chunk == 0 {
+		w.save(nil)
+	}
+	if w.err != nil {
+		return fmt.Errorf("failed to persist the log of revision %s of installation %q: %v", w.key.revision, w.key.installation, w.err)
+	}
+	return w.store.prune(w.key.installation)
+}
+
+type logReader struct {
+	store  LogStore
+	chunks []logKey
+	cur    *bytes.Reader
+}
+
+func (r *logReader) Read(p []byte) (int, error) {
+	for r.cur == nil || r.cur.Len() == 0 {
+		if len(r.chunks) == 0 {
+			return 0, io.EOF
+		}
+		data, err := r.store.backingStore.Read(LogsItemType, r.chunks[0].String())
+		if err != nil {
+			return 0, err
+		}
+		r.chunks = r.chunks[1:]
+		r.cur = bytes.NewReader(data)
+	}
+	return r.cur.Read(p)
+}
+
+func (r *logReader) Close() error {
+	r.chunks = nil
+	return nil
+}
@@ -0,0 +1,128 @@
+package claim
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cnabio/cnab-go/utils/crud"
+)
+
+func newTestLogStore(t *testing.T) (LogStore, crud.Store, func()) {
+	dir, err := ioutil.TempDir("", "cnabgotest")
+	require.NoError(t, err)
+	backing := crud.NewFileSystemStore(dir, "log")
+	return NewLogStore(backing), backing, func() { os.RemoveAll(dir) }
+}
+
+func writeLog(t *testing.T, s LogStore, installation, revision, content string) {
+	w, err := s.Create(installation, revision)
+	require.NoError(t, err)
+	_, err = io.WriteString(w, content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+}
+
+func readLog(t *testing.T, s LogStore, installation, revision string) string {
+	r, err := s.Open(installation, revision)
+	require.NoError(t, err)
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestLogStore(t *testing.T) {
+	s, backing, cleanup := newTestLogStore(t)
+	defer cleanup()
+	s.ChunkSize = 4
+
+	writeLog(t, s, "foo", "01A", "installing foo\n")
+	writeLog(t, s, "foo.bar", "01B", "installing foo.bar\n")
+	writeLog(t, s, "foo", "01C", "")
+
+	t.Run("logs are stored in chunks", func(t *testing.T) {
+		names, err := backing.List(LogsItemType)
+		require.NoError(t, err)
+		assert.Contains(t, names, "foo.01A.00000003")
+	})
+
+	t.Run("read", func(t *testing.T) {
+		assert.Equal(t, "installing foo\n", readLog(t, s, "foo", "01A"))
+		assert.Equal(t, "installing foo.bar\n", readLog(t, s, "foo.bar", "01B"))
+		assert.Equal(t, "", readLog(t, s, "foo", "01C"))
+
+		_, err := s.Open("foo", "01B")
+		assert.Equal(t, ErrLogNotFound, err)
+	})
+
+	t.Run("revisions", func(t *testing.T) {
+		revisions, err := s.Revisions("foo")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"01A", "01C"}, revisions)
+	})
+
+	t.Run("revisions with a dot are rejected", func(t *testing.T) {
+		_, err := s.Create("foo", "bar.01D")
+		assert.EqualError(t, err, `invalid revision "bar.01D" of claim "foo". Revisions must be [a-zA-Z0-9-_]+`)
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		writeLog(t, s, "foo", "01A", "retried\n")
+		assert.Equal(t, "retried\n", readLog(t, s, "foo", "01A"))
+	})
+
+	t.Run("partial chunks are persisted on write", func(t *testing.T) {
+		w, err := s.Create("foo", "01D")
+		require.NoError(t, err)
+		_, err = io.WriteString(w, "inst")
+		require.NoError(t, err)
+		_, err = io.WriteString(w, "all")
+		require.NoError(t, err)
+		// As if the process died before closing the log.
+		assert.Equal(t, "install", readLog(t, s, "foo", "01D"))
+		require.NoError(t, w.Close())
+		assert.Equal(t, "install", readLog(t, s, "foo", "01D"))
+		require.NoError(t, s.Delete("foo", "01D"))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, s.Delete("foo", "01A"))
+		_, err := s.Open("foo", "01A")
+		assert.Equal(t, ErrLogNotFound, err)
+		assert.Equal(t, ErrLogNotFound, s.Delete("foo", "01A"))
+	})
+}
+
+func TestLogStore_Retention(t *testing.T) {
+	s, _, cleanup := newTestLogStore(t)
+	defer cleanup()
+
+	t.Run("max revisions", func(t *testing.T) {
+		s.MaxRevisions = 2
+		for i := 1; i <= 4; i++ {
+			writeLog(t, s, "foo", fmt.Sprintf("01%d", i), "output")
+		}
+		revisions, err := s.Revisions("foo")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"013", "014"}, revisions)
+	})
+
+	t.Run("max size", func(t *testing.T) {
+		s.MaxSize = 10
+		w, err := s.Create("bar", "01A")
+		require.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			n, err := io.WriteString(w, "0123456")
+			require.NoError(t, err)
+			assert.Equal(t, 7, n)
+		}
+		require.NoError(t, w.Close())
+		assert.Equal(t, "0123456012\n[log truncated]\n", readLog(t, s, "bar", "01A"))
+	})
+}
@@ -0,0 +1,183 @@
+package claim
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/cnabio/cnab-go/utils/crud"
+)
+
+// SortField is a field that queried claims can be sorted by.
+type SortField string
+
+const (
+	// SortByName sorts claims by installation name.
+	SortByName SortField = ""
+	// SortByCreated sorts claims by creation time.
+	SortByCreated SortField = "created"
+	// SortByModified sorts claims by modification time.
+	SortByModified SortField = "modified"
+)
+
+// Query selects claims from a Store. Fields that are not set do not restrict
+// the selection.
+type Query struct {
+	// Bundle is the name of the bundle of the claims.
+	Bundle string
+	// BundleVersion is a semver constraint, such as ">= 1.0.0, < 2.0.0", that
+	// the bundle version of the claims satisfies. Bundles without a valid
+	// semver version are not selected.
+	BundleVersion string
+	// Action and Status are the action and status of the last result.
+	Action string
+	Status string
+	// ModifiedAfter and ModifiedBefore bound the time the claims were last
+	// modified. ModifiedAfter is inclusive, ModifiedBefore is exclusive.
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+	// Labels are all set on the claims, with the same values.
+	Labels map[string]string
+
+	SortBy     SortField
+	Descending bool
+	// Offset is the number of claims skipped, after sorting.
+	Offset int
+	// Limit is the maximum number of claims returned. All claims are returned
+	// when it is not set.
+	Limit int
+}
+
+// index returns the fields of the claim that queries are evaluated against.
+func index(c Claim) crud.Index {
+	idx := crud.Index{
+		"action":   c.Result.Action,
+		"status":   c.Result.Status,
+		"created":  c.Created.UnixNano(),
+		"modified": c.Modified.UnixNano(),
+	}
+	if c.Bundle != nil {
+		idx["bundle"] = c.Bundle.Name
+		idx["version"] = c.Bundle.Version
+	}
+	if len(c.Labels) > 0 {
+		idx["labels"] = labelPairs(c.Labels)
+	}
+	return idx
+}
+
+// labelPairs flattens labels to sorted "key=value" pairs, which label keys
+// such as "cnab.io/team" can be stored in, and queried on, by any store.
+func labelPairs(labels map[string]string) []string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+// crudQuery translates the query to the conditions evaluated by the backing
+// store. The bundle version constraint is not part of it.
+func (q Query) crudQuery() crud.Query {
+	var conds []crud.Condition
+	eq := func(field string, value interface{}) {
+		conds = append(conds, crud.Condition{Field: field, Operator: crud.OpEqual, Value: value})
+	}
+	if q.Bundle != "" {
+		eq("bundle", q.Bundle)
+	}
+	if q.Action != "" {
+		eq("action", q.Action)
+	}
+	if q.Status != "" {
+		eq("status", q.Status)
+	}
+	if !q.ModifiedAfter.IsZero() {
+		conds = append(conds, crud.Condition{Field: "modified", Operator: crud.OpGreaterOrEqual, Value: q.ModifiedAfter.UnixNano()})
+	}
+	if !q.ModifiedBefore.IsZero() {
+		conds = append(conds, crud.Condition{Field: "modified", Operator: crud.OpLess, Value: q.ModifiedBefore.UnixNano()})
+	}
+	for _, pair := range labelPairs(q.Labels) {
+		eq("labels", pair)
+	}
+
+	return crud.Query{
+		Conditions: conds,
+		SortBy:     string(q.SortBy),
+		Descending: q.Descending,
+		Skip:       q.Offset,
+		Limit:      q.Limit,
+	}
+}
+
+// Query returns the latest revision of the claims selected by the query.
+//
+// The query is evaluated against an index of the claims, by the backing store
+// when it supports queries, such as MongoDB. The bundle version constraint is
+// evaluated by the Store on the index, since semver ranges cannot be expressed
+// as store queries, so pagination is applied after it when it is set.
+//
+// Claims saved before the index was kept are only selected once Migrate has
+// been called.
+func (s Store) Query(q Query) ([]Claim, error) {
+	cq := q.crudQuery()
+
+	var version *semver.Constraints
+	if q.BundleVersion != "" {
+		var err error
+		version, err = semver.NewConstraint(q.BundleVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version constraint %q: %v", q.BundleVersion, err)
+		}
+		cq.Skip, cq.Limit = 0, 0
+	}
+
+	matches, err := s.backingStore.Query(ItemType, cq)
+	if err != nil {
+		return nil, err
+	}
+
+	if version != nil {
+		var selected []crud.Match
+		for _, m := range matches {
+			if satisfies(version, m.Index["version"]) {
+				selected = append(selected, m)
+			}
+		}
+		matches = page(selected, q.Offset, q.Limit)
+	}
+
+	claims := make([]Claim, 0, len(matches))
+	for _, m := range matches {
+		c, err := s.Read(m.Name)
+		if err != nil {
+			return nil, err
+		}
+		claims = append(claims, c)
+	}
+	return claims, nil
+}
+
+func satisfies(constraints *semver.Constraints, version interface{}) bool {
+	s, ok := version.(string)
+	if !ok {
+		return false
+	}
+	v, err := semver.NewVersion(s)
+	return err == nil && constraints.Check(v)
+}
+
+func page(matches []crud.Match, offset, limit int) []crud.Match {
+	if offset >= len(matches) {
+		return nil
+	}
+	matches = matches[offset:]
+	if limit > 0 && limit < len(matches) {
+		matches = matches[:limit]
+	}
+	return matches
+}
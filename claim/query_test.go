@@ -0,0 +1,78 @@
+package claim
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cnabio/cnab-go/bundle"
+	"github.com/cnabio/cnab-go/utils/crud"
+)
+
+func TestStore_Query(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "cnabgotest")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	store := NewClaimStore(crud.NewFileSystemStore(tempDir, "json"))
+
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	save := func(name, bundleName, version, status string, age time.Duration, labels map[string]string) {
+		c, err := New(name)
+		require.NoError(t, err)
+		c.Bundle = &bundle.Bundle{Name: bundleName, Version: version}
+		c.Update(ActionUpgrade, status)
+		c.Modified = start.Add(age)
+		c.Labels = labels
+		require.NoError(t, store.Save(*c))
+	}
+	save("a", "foo", "1.0.0", StatusFailure, 3*time.Hour, map[string]string{"team": "red"})
+	save("b", "foo", "1.2.0", StatusSuccess, 2*time.Hour, map[string]string{"team": "red", "env": "prod"})
+	save("c", "foo", "2.0.0", StatusFailure, 1*time.Hour, nil)
+	save("d", "bar", "1.0.0", StatusFailure, 4*time.Hour, map[string]string{"team": "blue"})
+
+	names := func(q Query) []string {
+		claims, err := store.Query(q)
+		require.NoError(t, err)
+		names := []string{}
+		for _, c := range claims {
+			names = append(names, c.Name)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"a", "b", "c", "d"}, names(Query{}))
+	assert.Equal(t, []string{"a", "c"}, names(Query{Bundle: "foo", Status: StatusFailure}))
+	assert.Equal(t, []string{"a", "b"}, names(Query{Bundle: "foo", BundleVersion: "< 2.0.0"}))
+	assert.Equal(t, []string{"a", "b"}, names(Query{Labels: map[string]string{"team": "red"}}))
+	assert.Equal(t, []string{"b"}, names(Query{Labels: map[string]string{"team": "red", "env": "prod"}}))
+	assert.Equal(t, []string{"a", "b"}, names(Query{ModifiedAfter: start.Add(2 * time.Hour), ModifiedBefore: start.Add(4 * time.Hour)}))
+	assert.Equal(t, []string{"a", "b", "c"}, names(Query{Action: ActionUpgrade, Bundle: "foo"}))
+
+	t.Run("sort and paginate", func(t *testing.T) {
+		assert.Equal(t, []string{"d", "a", "b", "c"}, names(Query{SortBy: SortByModified, Descending: true}))
+		assert.Equal(t, []string{"b", "a"}, names(Query{SortBy: SortByModified, Offset: 1, Limit: 2}))
+		assert.Equal(t, []string{"b"}, names(Query{BundleVersion: "^1", Offset: 1, Limit: 1}), "pagination should apply after the version constraint")
+	})
+
+	t.Run("invalid version constraint", func(t *testing.T) {
+		_, err := store.Query(Query{BundleVersion: "not a version"})
+		assert.EqualError(t, err, `invalid version constraint "not a version": improper constraint: not a version`)
+	})
+
+	t.Run("deleted claims are not selected", func(t *testing.T) {
+		require.NoError(t, store.Delete("a"))
+		assert.Equal(t, []string{"c"}, names(Query{Bundle: "foo", Status: StatusFailure}))
+	})
+
+	t.Run("migration indexes claims", func(t *testing.T) {
+		backing := crud.NewFileSystemStore(tempDir, "json")
+		require.NoError(t, backing.Delete(crud.IndexItemType(ItemType), "d"))
+		assert.Equal(t, []string{"b", "c"}, names(Query{}))
+		require.NoError(t, store.Migrate())
+		assert.Equal(t, []string{"b", "c", "d"}, names(Query{}))
+	})
+}
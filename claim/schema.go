@@ -0,0 +1,215 @@
+package claim
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/qri-io/jsonschema"
+)
+
+// DefaultSchemaVersion is the version of the CNAB claim schema that claims are
+// written with.
+const DefaultSchemaVersion = "v1.0.0-WD"
+
+// ErrUnsupportedSchemaVersion is returned when reading a claim written with a
+// version of the claim schema that is not supported.
+var ErrUnsupportedSchemaVersion = errors.New("unsupported claim schema version")
+
+// supportedSchemaVersions are the versions of the claim schema that can be read.
+var supportedSchemaVersions = map[string]bool{
+	DefaultSchemaVersion: true,
+}
+
+// schema is the CNAB claim JSON schema.
+//
+// The bundle is only required to be an object: it is validated against the
+// bundle schema by the bundle package, and the reference to the bundle schema
+// would have to be fetched. Properties that are not defined by the spec, such
+// as Result.Attempts, are allowed.
+const schema = `{
+  "$id": "https://cnab.io/v1/claim.schema.json",
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "CNAB Claims json schema",
+  "type": "object",
+  "properties": {
+    "schemaVersion": {
+      "description": "The version of the claim schema",
+      "type": "string"
+    },
+    "installation": {
+      "description": "The name of the installation",
+      "type": "string"
+    },
+    "revision": {
+      "description": "The revision ID (ideally a ULID)",
+      "type": "string"
+    },
+    "created": {
+      "description": "The date created, as an ISO-8601 Extended Format date string",
+      "type": "string"
+    },
+    "modified": {
+      "description": "The date last modified, as an ISO-8601 Extended Format date string",
+      "type": "string"
+    },
+    "bundle": {
+      "description": "The bundle descriptor",
+      "type": "object"
+    },
+    "bundleReference": {
+      "description": "A canonical reference to the bundle used in the last action",
+      "type": "string"
+    },
+    "result": {
+      "description": "The result of the last action",
+      "type": "object",
+      "properties": {
+        "action": {
+          "description": "The name of the action",
+          "type": "string"
+        },
+        "message": {
+          "description": "A human-readable string that communicates the outcome",
+          "type": "string"
+        },
+        "status": {
+          "description": "The status of the operation",
+          "type": "string",
+          "enum": ["canceled", "failure", "pending", "running", "success", "underway", "unknown"]
+        }
+      },
+      "required": ["action", "status"]
+    },
+    "parameters": {
+      "description": "Key/value pairs that were passed in during the operation",
+      "type": "object"
+    },
+    "outputs": {
+      "description": "Key/value pairs that were created by the operation",
+      "type": "object"
+    },
+    "outputEncodings": {
+      "description": "The encoding of the outputs not stored as they were written",
+      "type": "object",
+      "additionalProperties": {
+        "type": "string"
+      }
+    },
+    "labels": {
+      "description": "String labels identifying the installation",
+      "type": "object",
+      "additionalProperties": {
+        "type": "string"
+      }
+    },
+    "annotations": {
+      "description": "String annotations about the installation",
+      "type": "object",
+      "additionalProperties": {
+        "type": "string"
+      }
+    },
+    "custom": {
+      "$comment": "reserved for custom extensions"
+    }
+  },
+  "required": ["schemaVersion", "installation", "revision", "created", "modified", "bundle", "result"]
+}`
+
+var claimSchema = mustLoadSchema()
+
+func mustLoadSchema() *jsonschema.RootSchema {
+	rs := &jsonschema.RootSchema{}
+	if err := json.Unmarshal([]byte(schema), rs); err != nil {
+		panic(fmt.Sprintf("invalid claim schema: %v", err))
+	}
+	return rs
+}
+
+// Validate checks that the claim matches the claim schema.
+func (c Claim) Validate() error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return validate(c.Name, data)
+}
+
+// validate checks that the JSON document of a claim matches the claim schema,
+// and was written with a supported version of the schema.
+func validate(name string, data []byte) error {
+	valErrs, err := claimSchema.ValidateBytes(data)
+	if err != nil {
+		return fmt.Errorf("invalid claim %q: %v", name, err)
+	}
+	if len(valErrs) > 0 {
+		msgs := make([]string, len(valErrs))
+		for i, e := range valErrs {
+			msgs[i] = e.Error()
+		}
+		return fmt.Errorf("claim %q does not match the claim schema: %s", name, strings.Join(msgs, "; "))
+	}
+
+	var doc struct {
+		SchemaVersion string `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("invalid claim %q: %v", name, err)
+	}
+	if !supportedSchemaVersions[doc.SchemaVersion] {
+		return fmt.Errorf("claim %q uses schema version %s: %w", name, doc.SchemaVersion, ErrUnsupportedSchemaVersion)
+	}
+	return nil
+}
+
+// Parse reads a claim from its JSON document. Claims written before the schema
+// was versioned are migrated to the current schema. The claim is validated
+// against the claim schema.
+func Parse(data []byte) (Claim, error) {
+	data, _, err := migrate(data)
+	if err != nil {
+		return Claim{}, err
+	}
+
+	var claim Claim
+	if err := json.Unmarshal(data, &claim); err != nil {
+		return Claim{}, fmt.Errorf("error unmarshaling claim: %v", err)
+	}
+	if err := validate(claim.Name, data); err != nil {
+		return Claim{}, err
+	}
+	return claim, nil
+}
+
+// migrate converts the JSON document of a claim written before the schema was
+// versioned, which named the installation "name", to the current schema. It
+// reports whether the document was migrated.
+func migrate(data []byte) ([]byte, bool, error) {
+	// Keep numbers as they were written, rather than as float64.
+	var doc map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, false, fmt.Errorf("error unmarshaling claim: %v", err)
+	}
+	if _, ok := doc["schemaVersion"]; ok {
+		return data, false, nil
+	}
+
+	if _, ok := doc["installation"]; !ok {
+		if name, ok := doc["name"]; ok {
+			doc["installation"] = name
+		}
+	}
+	delete(doc, "name")
+	doc["schemaVersion"] = DefaultSchemaVersion
+
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return nil, false, err
+	}
+	return migrated, true, nil
+}
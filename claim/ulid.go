@@ -0,0 +1,90 @@
+package claim
+
+import (
+	"crypto/rand"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid"
+)
+
+// DefaultULIDGenerator generates the ULIDs returned by ULID, from the current
+// time and cryptographically secure random numbers. Tests that need
+// deterministic revisions can replace it with a generator of their own.
+var DefaultULIDGenerator = NewULIDGenerator(nil, nil)
+
+// ULIDGenerator generates ULIDs that are strictly increasing, so that they sort
+// in the order in which they were generated, including when several are
+// generated within the same millisecond or when the clock goes backwards.
+//
+// The first ULID of each millisecond gets fresh entropy, and the following ones
+// increment the entropy of the previous one. ULIDGenerator is safe for
+// concurrent use.
+type ULIDGenerator struct {
+	clock   func() time.Time
+	entropy io.Reader
+
+	mu   sync.Mutex
+	last ulid.ULID
+}
+
+// NewULIDGenerator creates a generator that reads the time from clock, and the
+// random part of the ULIDs from entropy. They default to time.Now and
+// crypto/rand when nil.
+func NewULIDGenerator(clock func() time.Time, entropy io.Reader) *ULIDGenerator {
+	if clock == nil {
+		clock = time.Now
+	}
+	if entropy == nil {
+		entropy = rand.Reader
+	}
+	return &ULIDGenerator{clock: clock, entropy: entropy}
+}
+
+// New generates a ULID greater than every ULID previously generated.
+func (g *ULIDGenerator) New() (ulid.ULID, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := ulid.Timestamp(g.clock())
+	if last := g.last.Time(); ms <= last {
+		id, ok := increment(g.last)
+		if ok {
+			g.last = id
+			return id, nil
+		}
+		// The entropy of the millisecond is exhausted: move on to the next one.
+		ms = last + 1
+	}
+
+	id, err := ulid.New(ms, g.entropy)
+	if err != nil {
+		return ulid.ULID{}, err
+	}
+	g.last = id
+	return id, nil
+}
+
+// increment returns the ULID with the same time and the next entropy, and
+// whether the entropy did not overflow.
+func increment(id ulid.ULID) (ulid.ULID, bool) {
+	// The entropy is the last 10 bytes, in big-endian order.
+	for i := len(id) - 1; i >= 6; i-- {
+		id[i]++
+		if id[i] != 0 {
+			return id, true
+		}
+	}
+	return id, false
+}
+
+// ULID generates a string representation of a ULID with DefaultULIDGenerator.
+// It panics when no entropy can be read.
+func ULID() string {
+	id, err := DefaultULIDGenerator.New()
+	if err != nil {
+		panic(err)
+	}
+	return id.String()
+}
@@ -0,0 +1,114 @@
+package claim
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// zeros is an entropy source that reads zeros, or 0xff when full is set.
+type zeros struct{ full bool }
+
+func (z zeros) Read(p []byte) (int, error) {
+	fill := byte(0)
+	if z.full {
+		fill = 0xff
+	}
+	for i := range p {
+		p[i] = fill
+	}
+	return len(p), nil
+}
+
+func TestULIDGenerator(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	t.Run("deterministic", func(t *testing.T) {
+		g := NewULIDGenerator(clock, zeros{})
+		id, err := g.New()
+		require.NoError(t, err)
+		assert.Equal(t, ulid.Timestamp(now), id.Time())
+		assert.Equal(t, make([]byte, 10), id.Entropy())
+
+		next, err := g.New()
+		require.NoError(t, err)
+		assert.Equal(t, ulid.Timestamp(now), next.Time())
+		assert.Equal(t, append(make([]byte, 9), 1), next.Entropy(), "the entropy should be incremented within a millisecond")
+	})
+
+	t.Run("clock going backwards", func(t *testing.T) {
+		current := now
+		g := NewULIDGenerator(func() time.Time { return current }, zeros{})
+		first, err := g.New()
+		require.NoError(t, err)
+
+		current = now.Add(-time.Second)
+		second, err := g.New()
+		require.NoError(t, err)
+		assert.Equal(t, 1, second.Compare(first))
+		assert.Equal(t, first.Time(), second.Time())
+	})
+
+	t.Run("entropy overflow", func(t *testing.T) {
+		g := NewULIDGenerator(clock, zeros{full: true})
+		first, err := g.New()
+		require.NoError(t, err)
+
+		second, err := g.New()
+		require.NoError(t, err)
+		assert.Equal(t, 1, second.Compare(first))
+		assert.Equal(t, first.Time()+1, second.Time(), "the next millisecond should be used")
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		g := NewULIDGenerator(clock, nil)
+		const workers, perWorker = 8, 100
+
+		var mu sync.Mutex
+		var ids []string
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var generated []string
+				for i := 0; i < perWorker; i++ {
+					id, err := g.New()
+					if !assert.NoError(t, err) {
+						return
+					}
+					generated = append(generated, id.String())
+				}
+				assert.True(t, sort.StringsAreSorted(generated), "the ULIDs of a goroutine should be increasing")
+				mu.Lock()
+				ids = append(ids, generated...)
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		unique := map[string]bool{}
+		for _, id := range ids {
+			unique[id] = true
+		}
+		assert.Len(t, unique, workers*perWorker)
+	})
+}
+
+func TestULID_Sorted(t *testing.T) {
+	ids := make([]string, 1000)
+	for i := range ids {
+		ids[i] = ULID()
+	}
+	assert.True(t, sort.StringsAreSorted(ids), "ULIDs should sort in the order in which they were generated")
+
+	_, err := ulid.ParseStrict(ids[0])
+	assert.NoError(t, err)
+	assert.NotEqual(t, ids[0], ids[1])
+}
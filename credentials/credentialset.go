@@ -20,9 +20,13 @@ type Set map[string]string
 //
 // This matches the credentials required by the bundle to the credentials present
 // in the credentialset, and then expands them per the definition in the Bundle.
-func (s Set) Expand(b *bundle.Bundle, stateless bool) (env, files map[string]string, err error) {
+// Credentials that do not apply to the action are neither required nor expanded.
+func (s Set) Expand(b *bundle.Bundle, action string, stateless bool) (env, files map[string]string, err error) {
 	env, files = map[string]string{}, map[string]string{}
 	for name, val := range b.Credentials {
+		if !val.AppliesTo(action) {
+			continue
+		}
 		src, ok := s[name]
 		if !ok {
 			if stateless || !val.Required {
@@ -44,15 +48,10 @@ func (s Set) Expand(b *bundle.Bundle, stateless bool) (env, files map[string]str
 // Merge merges a second Set into the base.
 //
 // Duplicate credential names are not allow and will result in an
-// error, this is the case even if the values are identical.
+// error, this is the case even if the values are identical. Use MergeUsing
+// to override or keep duplicate credentials instead.
 func (s Set) Merge(s2 Set) error {
-	for k, v := range s2 {
-		if _, ok := s[k]; ok {
-			return fmt.Errorf("ambiguous credential resolution: %q is already present in base credential sets, cannot merge", k)
-		}
-		s[k] = v
-	}
-	return nil
+	return s.MergeUsing(s2, MergeError)
 }
 
 // CredentialSet represents a collection of credentials
@@ -84,12 +83,13 @@ func Load(path string) (*CredentialSet, error) {
 // This will result in an error only when the following conditions are true:
 // - a credential in the spec is not present in the given set
 // - the credential is required
+// - the credential applies to the action
 //
 // It is allowed for spec to specify both an env var and a file. In such case, if
 // the given set provides either, it will be considered valid.
-func Validate(given Set, spec map[string]bundle.Credential) error {
+func Validate(given Set, spec map[string]bundle.Credential, action string) error {
 	for name, cred := range spec {
-		if !isValidCred(given, name) && cred.Required {
+		if !isValidCred(given, name) && cred.Required && cred.AppliesTo(action) {
 			return fmt.Errorf("bundle requires credential for %s", name)
 		}
 	}
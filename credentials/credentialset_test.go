@@ -80,7 +80,7 @@ func TestCredentialSet_Expand(t *testing.T) {
 		"third":  "third",
 	}
 
-	env, path, err := cs.Expand(b, false)
+	env, path, err := cs.Expand(b, "install", false)
 	is := assert.New(t)
 	is.NoError(err)
 	for k, v := range b.Credentials {
@@ -130,12 +130,35 @@ func TestCredentialSetMissingRequiredCred(t *testing.T) {
 		},
 	}
 	cs := Set{}
-	_, _, err := cs.Expand(b, false)
+	_, _, err := cs.Expand(b, "install", false)
 	assert.EqualError(t, err, `credential "first" is missing from the user-supplied credentials`)
-	_, _, err = cs.Expand(b, true)
+	_, _, err = cs.Expand(b, "install", true)
 	assert.NoError(t, err)
 }
 
+func TestCredentialSetRequiredCredSpecificToAction(t *testing.T) {
+	b := &bundle.Bundle{
+		Name: "knapsack",
+		Credentials: map[string]bundle.Credential{
+			"admin": {
+				Location: bundle.Location{
+					EnvironmentVariable: "ADMIN_VAR",
+				},
+				Required: true,
+				ApplyTo:  []string{"install"},
+			},
+		},
+	}
+	_, _, err := Set{}.Expand(b, "install", false)
+	assert.EqualError(t, err, `credential "admin" is missing from the user-supplied credentials`)
+	assert.Error(t, Validate(Set{}, b.Credentials, "install"))
+
+	env, _, err := Set{"admin": "root"}.Expand(b, "status", false)
+	assert.NoError(t, err)
+	assert.Empty(t, env, "credentials that do not apply to the action should not be expanded")
+	assert.NoError(t, Validate(Set{}, b.Credentials, "status"))
+}
+
 func TestCredentialSetMissingOptionalCred(t *testing.T) {
 	b := &bundle.Bundle{
 		Name: "knapsack",
@@ -148,8 +171,8 @@ func TestCredentialSetMissingOptionalCred(t *testing.T) {
 		},
 	}
 	cs := Set{}
-	_, _, err := cs.Expand(b, false)
+	_, _, err := cs.Expand(b, "install", false)
 	assert.NoError(t, err)
-	_, _, err = cs.Expand(b, true)
+	_, _, err = cs.Expand(b, "install", true)
 	assert.NoError(t, err)
 }
@@ -0,0 +1,145 @@
+package credentials
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cnabio/cnab-go/secrets/host"
+)
+
+// MergeStrategy decides which value is kept when credentials with the same name
+// are merged.
+type MergeStrategy string
+
+const (
+	// MergeError fails the merge. It is the default strategy.
+	MergeError MergeStrategy = "error"
+	// MergeOverride keeps the value merged last.
+	MergeOverride MergeStrategy = "override"
+	// MergeKeepFirst keeps the value merged first.
+	MergeKeepFirst MergeStrategy = "keep-first"
+)
+
+// MergeUsing merges a second Set into the base, resolving the credentials
+// present in both with the strategy.
+func (s Set) MergeUsing(s2 Set, strategy MergeStrategy) error {
+	for k, v := range s2 {
+		if _, ok := s[k]; ok {
+			keep, err := keepExisting(k, strategy)
+			if err != nil {
+				return err
+			}
+			if keep {
+				continue
+			}
+		}
+		s[k] = v
+	}
+	return nil
+}
+
+// keepExisting reports whether the strategy keeps the value of a credential that
+// is already present over the value being merged.
+func keepExisting(name string, strategy MergeStrategy) (bool, error) {
+	switch strategy {
+	case MergeError, "":
+		return false, fmt.Errorf("ambiguous credential resolution: %q is already present in base credential sets, cannot merge", name)
+	case MergeOverride:
+		return false, nil
+	case MergeKeepFirst:
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown merge strategy %q", strategy)
+	}
+}
+
+// Origin records where the value of a credential comes from, without the
+// value itself.
+type Origin struct {
+	// CredentialSet is the name of the credential set the value comes from.
+	CredentialSet string
+	// Source is the secret source the value is resolved from. Values given
+	// inline in the credential set are redacted.
+	Source Source
+	// Shadowed are the other credential sets that define the credential, whose
+	// values are not used, in the order they were layered.
+	Shadowed []string
+}
+
+func (o Origin) String() string {
+	s := fmt.Sprintf("credential set %q", o.CredentialSet)
+	if o.Source.Key != "" {
+		s += fmt.Sprintf(", %s %s", o.Source.Key, o.Source.Value)
+	}
+	if len(o.Shadowed) > 0 {
+		s += fmt.Sprintf(" (shadowing %s)", strings.Join(o.Shadowed, ", "))
+	}
+	return s
+}
+
+// Provenance maps the names of credentials to their origin.
+type Provenance map[string]Origin
+
+// redactedValue replaces the values given inline in credential sets.
+const redactedValue = "(redacted)"
+
+// redact returns the source without the credential value it may hold.
+func redact(src Source) Source {
+	if strings.EqualFold(src.Key, host.SourceValue) {
+		src.Value = redactedValue
+	}
+	return src
+}
+
+// Layer merges credential sets, listed from the lowest to the highest
+// precedence: with MergeOverride, a per-environment set layered after a
+// team-wide set overrides its credentials. Credentials defined more than once
+// are resolved with the strategy.
+//
+// The merged set is named after the layered sets. It is returned with the
+// provenance of each of its credentials. The credentials are not resolved.
+func Layer(strategy MergeStrategy, sets ...*CredentialSet) (*CredentialSet, Provenance, error) {
+	merged := &CredentialSet{}
+	provenance := Provenance{}
+	index := map[string]int{}
+
+	var names []string
+	for _, cs := range sets {
+		names = append(names, cs.Name)
+		if merged.Created.IsZero() || cs.Created.Before(merged.Created) {
+			merged.Created = cs.Created
+		}
+		if cs.Modified.After(merged.Modified) {
+			merged.Modified = cs.Modified
+		}
+
+		for _, cred := range cs.Credentials {
+			origin := Origin{CredentialSet: cs.Name, Source: redact(cred.Source)}
+
+			i, ok := index[cred.Name]
+			if !ok {
+				index[cred.Name] = len(merged.Credentials)
+				merged.Credentials = append(merged.Credentials, cred)
+				provenance[cred.Name] = origin
+				continue
+			}
+
+			keep, err := keepExisting(cred.Name, strategy)
+			if err != nil {
+				return nil, nil, fmt.Errorf("credential set %q: %v", cs.Name, err)
+			}
+			previous := provenance[cred.Name]
+			if keep {
+				previous.Shadowed = append(previous.Shadowed, cs.Name)
+				provenance[cred.Name] = previous
+				continue
+			}
+			merged.Credentials[i] = cred
+			origin.Shadowed = append(previous.Shadowed, previous.CredentialSet)
+			provenance[cred.Name] = origin
+		}
+	}
+	merged.Name = strings.Join(names, "+")
+
+	return merged, provenance, nil
+}
@@ -0,0 +1,103 @@
+package credentials
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cnabio/cnab-go/secrets/host"
+)
+
+func TestSet_MergeUsing(t *testing.T) {
+	base := func() Set {
+		return Set{"first": "first", "second": "second"}
+	}
+
+	s := base()
+	err := s.MergeUsing(Set{"second": "bis", "third": "third"}, MergeOverride)
+	require.NoError(t, err)
+	assert.Equal(t, Set{"first": "first", "second": "bis", "third": "third"}, s)
+
+	s = base()
+	err = s.MergeUsing(Set{"second": "bis", "third": "third"}, MergeKeepFirst)
+	require.NoError(t, err)
+	assert.Equal(t, Set{"first": "first", "second": "second", "third": "third"}, s)
+
+	s = base()
+	err = s.MergeUsing(Set{"second": "bis"}, MergeError)
+	assert.EqualError(t, err, `ambiguous credential resolution: "second" is already present in base credential sets, cannot merge`)
+
+	err = base().MergeUsing(Set{"second": "bis"}, "newest")
+	assert.EqualError(t, err, `unknown merge strategy "newest"`)
+}
+
+func TestLayer(t *testing.T) {
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	team := &CredentialSet{
+		Name:     "team",
+		Created:  created,
+		Modified: created,
+		Credentials: []CredentialStrategy{
+			{Name: "github", Source: Source{Key: host.SourceEnv, Value: "TEAM_GITHUB_TOKEN"}},
+			{Name: "registry", Source: Source{Key: host.SourceValue, Value: "team-password"}},
+		},
+	}
+	prod := &CredentialSet{
+		Name:     "prod",
+		Created:  created.Add(time.Hour),
+		Modified: created.Add(time.Hour),
+		Credentials: []CredentialStrategy{
+			{Name: "github", Source: Source{Key: host.SourceValue, Value: "prod-token"}},
+			{Name: "kubeconfig", Source: Source{Key: host.SourcePath, Value: "testdata/someconfig.txt"}},
+		},
+	}
+
+	t.Run("override", func(t *testing.T) {
+		merged, provenance, err := Layer(MergeOverride, team, prod)
+		require.NoError(t, err)
+		assert.Equal(t, "team+prod", merged.Name)
+		assert.Equal(t, created, merged.Created)
+		assert.Equal(t, created.Add(time.Hour), merged.Modified)
+		assert.Equal(t, []CredentialStrategy{
+			{Name: "github", Source: Source{Key: host.SourceValue, Value: "prod-token"}},
+			{Name: "registry", Source: Source{Key: host.SourceValue, Value: "team-password"}},
+			{Name: "kubeconfig", Source: Source{Key: host.SourcePath, Value: "testdata/someconfig.txt"}},
+		}, merged.Credentials)
+
+		assert.Equal(t, Provenance{
+			"github":     {CredentialSet: "prod", Source: Source{Key: host.SourceValue, Value: "(redacted)"}, Shadowed: []string{"team"}},
+			"registry":   {CredentialSet: "team", Source: Source{Key: host.SourceValue, Value: "(redacted)"}},
+			"kubeconfig": {CredentialSet: "prod", Source: Source{Key: host.SourcePath, Value: "testdata/someconfig.txt"}},
+		}, provenance)
+		assert.Equal(t, `credential set "prod", value (redacted) (shadowing team)`, provenance["github"].String())
+
+		resolved, err := merged.ResolveCredentials(&host.SecretStore{})
+		require.NoError(t, err)
+		assert.Equal(t, "prod-token", resolved["github"])
+	})
+
+	t.Run("keep first", func(t *testing.T) {
+		merged, provenance, err := Layer(MergeKeepFirst, team, prod)
+		require.NoError(t, err)
+		assert.Equal(t, Source{Key: host.SourceEnv, Value: "TEAM_GITHUB_TOKEN"}, merged.Credentials[0].Source)
+		assert.Equal(t, Origin{
+			CredentialSet: "team",
+			Source:        Source{Key: host.SourceEnv, Value: "TEAM_GITHUB_TOKEN"},
+			Shadowed:      []string{"prod"},
+		}, provenance["github"])
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, _, err := Layer(MergeError, team, prod)
+		assert.EqualError(t, err, `credential set "prod": ambiguous credential resolution: "github" is already present in base credential sets, cannot merge`)
+	})
+
+	t.Run("the layered sets are not modified", func(t *testing.T) {
+		_, _, err := Layer(MergeOverride, team, prod)
+		require.NoError(t, err)
+		assert.Equal(t, "TEAM_GITHUB_TOKEN", team.Credentials[0].Source.Value)
+		assert.Len(t, team.Credentials, 2)
+	})
+}
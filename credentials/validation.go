@@ -0,0 +1,93 @@
+package credentials
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cnabio/cnab-go/bundle"
+)
+
+// ValidationReport describes how the credentials supplied for an action match
+// the credentials defined by a bundle.
+type ValidationReport struct {
+	Action string
+	// Missing are the credentials required by the bundle for the action, that
+	// were not supplied.
+	Missing []string
+	// Extra are the credentials supplied that the bundle does not define.
+	Extra []string
+	// NotApplicable are the credentials supplied that the bundle defines, but
+	// that do not apply to the action.
+	NotApplicable []string
+}
+
+// Valid reports whether every credential required for the action was supplied.
+// Extra and not applicable credentials are ignored by the actions, so they do
+// not make the report invalid.
+func (r ValidationReport) Valid() bool {
+	return len(r.Missing) == 0
+}
+
+// Err returns an error listing the missing credentials, or nil when the report
+// is valid.
+func (r ValidationReport) Err() error {
+	if r.Valid() {
+		return nil
+	}
+	return fmt.Errorf("bundle requires credentials for the %s action: %s", r.Action, strings.Join(r.Missing, ", "))
+}
+
+// Validate reports how the credentials of the set match the credentials of the
+// bundle for the action. Only the names of the credentials are compared, so the
+// set does not need to be resolved.
+func (c *CredentialSet) Validate(b *bundle.Bundle, action string) ValidationReport {
+	names := make([]string, len(c.Credentials))
+	for i, cred := range c.Credentials {
+		names[i] = cred.Name
+	}
+	return validate(names, b, action)
+}
+
+// Validate reports how the credentials of the set match the credentials of the
+// bundle for the action.
+func (s Set) Validate(b *bundle.Bundle, action string) ValidationReport {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	return validate(names, b, action)
+}
+
+func validate(supplied []string, b *bundle.Bundle, action string) ValidationReport {
+	report := ValidationReport{Action: action}
+
+	given := make(map[string]bool, len(supplied))
+	for _, name := range supplied {
+		if given[name] {
+			continue
+		}
+		given[name] = true
+
+		cred, ok := b.Credentials[name]
+		switch {
+		case !ok:
+			report.Extra = append(report.Extra, name)
+		case !cred.AppliesTo(action):
+			report.NotApplicable = append(report.NotApplicable, name)
+		}
+	}
+
+	// Stateless actions do not require credentials.
+	stateless := b.Actions[action].Stateless
+	for name, cred := range b.Credentials {
+		if cred.Required && cred.AppliesTo(action) && !stateless && !given[name] {
+			report.Missing = append(report.Missing, name)
+		}
+	}
+
+	sort.Strings(report.Missing)
+	sort.Strings(report.Extra)
+	sort.Strings(report.NotApplicable)
+	return report
+}
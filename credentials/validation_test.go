@@ -0,0 +1,64 @@
+package credentials
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cnabio/cnab-go/bundle"
+)
+
+func TestValidate(t *testing.T) {
+	b := &bundle.Bundle{
+		Name: "knapsack",
+		Credentials: map[string]bundle.Credential{
+			"kubeconfig": {Location: bundle.Location{Path: "/root/.kube/config"}, Required: true},
+			"token":      {Location: bundle.Location{EnvironmentVariable: "TOKEN"}, Required: true},
+			"registry":   {Location: bundle.Location{EnvironmentVariable: "REGISTRY"}},
+			"admin":      {Location: bundle.Location{EnvironmentVariable: "ADMIN"}, Required: true, ApplyTo: []string{"install", "upgrade", "uninstall"}},
+		},
+		Actions: map[string]bundle.Action{
+			"logs": {Stateless: true},
+		},
+	}
+
+	t.Run("credential set", func(t *testing.T) {
+		cs := &CredentialSet{
+			Name: "dev",
+			Credentials: []CredentialStrategy{
+				{Name: "kubeconfig", Source: Source{Key: "path", Value: "/home/me/.kube/config"}},
+				{Name: "github", Source: Source{Key: "env", Value: "GITHUB_TOKEN"}},
+				{Name: "aws", Source: Source{Key: "env", Value: "AWS_TOKEN"}},
+			},
+		}
+		report := cs.Validate(b, "install")
+		assert.Equal(t, ValidationReport{
+			Action:  "install",
+			Missing: []string{"admin", "token"},
+			Extra:   []string{"aws", "github"},
+		}, report)
+		assert.False(t, report.Valid())
+		assert.EqualError(t, report.Err(), "bundle requires credentials for the install action: admin, token")
+	})
+
+	t.Run("resolved set", func(t *testing.T) {
+		report := Set{"kubeconfig": "...", "token": "...", "admin": "..."}.Validate(b, "upgrade")
+		assert.True(t, report.Valid())
+		assert.NoError(t, report.Err())
+		assert.Empty(t, report.Extra)
+	})
+
+	t.Run("credential not applicable to the action", func(t *testing.T) {
+		report := Set{"kubeconfig": "...", "token": "...", "admin": "..."}.Validate(b, "status")
+		assert.True(t, report.Valid())
+		assert.Equal(t, []string{"admin"}, report.NotApplicable)
+
+		report = Set{"kubeconfig": "...", "token": "..."}.Validate(b, "status")
+		assert.True(t, report.Valid(), "credentials that do not apply to the action are not required")
+	})
+
+	t.Run("stateless action", func(t *testing.T) {
+		report := Set{}.Validate(b, "logs")
+		assert.True(t, report.Valid(), "stateless actions do not require credentials")
+	})
+}
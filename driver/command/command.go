@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/cnabio/cnab-go/driver"
@@ -113,18 +114,33 @@ func (d *Driver) exec(op *driver.Operation) (driver.OperationResult, error) {
 	}()
 
 	if err = cmd.Start(); err != nil {
-		return driver.OperationResult{}, fmt.Errorf("Start of driver (%s) failed: %v", d.Name, err)
+		return driver.OperationResult{}, driver.NotStarted(fmt.Errorf("Start of driver (%s) failed: %v", d.Name, err))
 	}
 
+	opID := strconv.Itoa(cmd.Process.Pid)
 	if err = cmd.Wait(); err != nil {
-		return driver.OperationResult{}, fmt.Errorf("Command driver (%s) failed executing bundle: %v", d.Name, err)
+		return d.describe(driver.OperationResult{}, opID, cmd), fmt.Errorf("Command driver (%s) failed executing bundle: %v", d.Name, err)
 	}
 
 	result, err := d.getOperationResult(op)
 	if err != nil {
-		return driver.OperationResult{}, fmt.Errorf("Command driver (%s) failed getting operation result: %v", d.Name, err)
+		return d.describe(driver.OperationResult{}, opID, cmd), fmt.Errorf("Command driver (%s) failed getting operation result: %v", d.Name, err)
 	}
-	return result, nil
+	return d.describe(result, opID, cmd), nil
+}
+
+// describe records how the operation ran on the result: the driver, the
+// process ID of the command and its exit code.
+func (d *Driver) describe(result driver.OperationResult, opID string, cmd *exec.Cmd) driver.OperationResult {
+	result.Driver = d.Name
+	result.OperationID = opID
+	if cmd.ProcessState != nil {
+		exitCode := cmd.ProcessState.ExitCode()
+		if exitCode >= 0 {
+			result.ExitCode = &exitCode
+		}
+	}
+	return result
 }
 func (d *Driver) getOperationResult(op *driver.Operation) (driver.OperationResult, error) {
 	opResult := driver.OperationResult{
@@ -66,6 +66,11 @@ func TestCommandDriverOutputs(t *testing.T) {
 			"/cnab/app/outputs/output1": "TEST_OUTPUT_1\n",
 			"/cnab/app/outputs/output2": "TEST_OUTPUT_2\n",
 		}, opResult.Outputs)
+		assert.Equal(t, cmddriver.Name, opResult.Driver)
+		assert.NotEmpty(t, opResult.OperationID, "the process ID should be reported")
+		if assert.NotNil(t, opResult.ExitCode) {
+			assert.Equal(t, 0, *opResult.ExitCode)
+		}
 	}
 	CreateAndRunTestCommandDriver(t, name, content, testfunc)
 	// Test for an output missing and no defaults
@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"os"
 	unix_path "path"
+	"strings"
 
 	"github.com/cnabio/cnab-go/driver"
 	"github.com/docker/cli/cli/command"
@@ -15,6 +16,7 @@ import (
 	"github.com/docker/distribution/reference"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/strslice"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/jsonmessage"
@@ -139,7 +141,7 @@ func (d *Driver) exec(op *driver.Operation) (driver.OperationResult, error) {
 
 	cli, err := d.initializeDockerCli()
 	if err != nil {
-		return driver.OperationResult{}, err
+		return driver.OperationResult{}, driver.NotStarted(err)
 	}
 
 	if d.Simulate {
@@ -147,7 +149,7 @@ func (d *Driver) exec(op *driver.Operation) (driver.OperationResult, error) {
 	}
 	if d.config["PULL_ALWAYS"] == "1" {
 		if err := pullImage(ctx, cli, op.Image.Image); err != nil {
-			return driver.OperationResult{}, err
+			return driver.OperationResult{}, driver.NotStarted(err)
 		}
 	}
 	var env []string
@@ -161,6 +163,7 @@ func (d *Driver) exec(op *driver.Operation) (driver.OperationResult, error) {
 		Entrypoint:   strslice.StrSlice{"/cnab/app/run"},
 		AttachStderr: true,
 		AttachStdout: true,
+		Labels:       containerLabels(op),
 	}
 
 	hostCfg := &container.HostConfig{}
@@ -175,13 +178,13 @@ func (d *Driver) exec(op *driver.Operation) (driver.OperationResult, error) {
 	case client.IsErrNotFound(err):
 		fmt.Fprintf(cli.Err(), "Unable to find image '%s' locally\n", op.Image.Image)
 		if err := pullImage(ctx, cli, op.Image.Image); err != nil {
-			return driver.OperationResult{}, err
+			return driver.OperationResult{}, driver.NotStarted(err)
 		}
 		if resp, err = cli.Client().ContainerCreate(ctx, cfg, hostCfg, nil, ""); err != nil {
-			return driver.OperationResult{}, fmt.Errorf("cannot create container: %v", err)
+			return driver.OperationResult{}, driver.NotStarted(fmt.Errorf("cannot create container: %v", err))
 		}
 	case err != nil:
-		return driver.OperationResult{}, fmt.Errorf("cannot create container: %v", err)
+		return driver.OperationResult{}, driver.NotStarted(fmt.Errorf("cannot create container: %v", err))
 	}
 
 	if d.config["CLEANUP_CONTAINERS"] == "true" {
@@ -199,7 +202,7 @@ func (d *Driver) exec(op *driver.Operation) (driver.OperationResult, error) {
 	// path from the given file, starting at the /.
 	err = cli.Client().CopyToContainer(ctx, resp.ID, "/", tarContent, options)
 	if err != nil {
-		return driver.OperationResult{}, fmt.Errorf("error copying to / in container: %s", err)
+		return driver.OperationResult{}, driver.NotStarted(fmt.Errorf("error copying to / in container: %s", err))
 	}
 
 	attach, err := cli.Client().ContainerAttach(ctx, resp.ID, types.ContainerAttachOptions{
@@ -209,12 +212,16 @@ func (d *Driver) exec(op *driver.Operation) (driver.OperationResult, error) {
 		Logs:   true,
 	})
 	if err != nil {
-		return driver.OperationResult{}, fmt.Errorf("unable to retrieve logs: %v", err)
+		return driver.OperationResult{}, driver.NotStarted(fmt.Errorf("unable to retrieve logs: %v", err))
 	}
 	var (
 		stdout io.Writer = os.Stdout
 		stderr io.Writer = os.Stderr
 	)
+	if op.Out != nil {
+		stdout = op.Out
+		stderr = op.Out
+	}
 	if d.containerOut != nil {
 		stdout = d.containerOut
 	}
@@ -232,33 +239,139 @@ func (d *Driver) exec(op *driver.Operation) (driver.OperationResult, error) {
 	}()
 
 	if err = cli.Client().ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
-		return driver.OperationResult{}, fmt.Errorf("cannot start container: %v", err)
+		return driver.OperationResult{}, driver.NotStarted(fmt.Errorf("cannot start container: %v", err))
 	}
 	statusc, errc := cli.Client().ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
 	select {
 	case err := <-errc:
 		if err != nil {
 			opResult, fetchErr := d.fetchOutputs(ctx, resp.ID, op)
-			return opResult, containerError("error in container", err, fetchErr)
+			return d.describe(ctx, opResult, resp.ID, nil), containerError("error in container", err, fetchErr)
 		}
 	case s := <-statusc:
+		exitCode := int(s.StatusCode)
+		opResult, fetchErr := d.fetchOutputs(ctx, resp.ID, op)
+		opResult = d.describe(ctx, opResult, resp.ID, &exitCode)
 		if s.StatusCode == 0 {
-			return d.fetchOutputs(ctx, resp.ID, op)
-		}
-		if s.Error != nil {
-			opResult, fetchErr := d.fetchOutputs(ctx, resp.ID, op)
-			return opResult, containerError(fmt.Sprintf("container exit code: %d, message", s.StatusCode), err, fetchErr)
+			return opResult, fetchErr
 		}
-		opResult, fetchErr := d.fetchOutputs(ctx, resp.ID, op)
 		return opResult, containerError(fmt.Sprintf("container exit code: %d, message", s.StatusCode), err, fetchErr)
 	}
 	opResult, fetchErr := d.fetchOutputs(ctx, resp.ID, op)
+	opResult = d.describe(ctx, opResult, resp.ID, nil)
 	if fetchErr != nil {
 		return opResult, fmt.Errorf("fetching outputs failed: %s", fetchErr)
 	}
 	return opResult, err
 }
 
+// describe records how the operation ran on the result: the container, the
+// digest of the image it ran, and its exit code when known.
+func (d *Driver) describe(ctx context.Context, opResult driver.OperationResult, containerID string, exitCode *int) driver.OperationResult {
+	opResult.Driver = "docker"
+	opResult.OperationID = containerID
+	opResult.ExitCode = exitCode
+
+	info, err := d.dockerCli.Client().ContainerInspect(ctx, containerID)
+	if err != nil {
+		return opResult
+	}
+	opResult.ImageDigest = info.Image
+	// Prefer the digest of the image in its repository over the local image ID.
+	if img, _, err := d.dockerCli.Client().ImageInspectWithRaw(ctx, info.Image); err == nil {
+		for _, repoDigest := range img.RepoDigests {
+			if i := strings.LastIndex(repoDigest, "@"); i >= 0 {
+				opResult.ImageDigest = repoDigest[i+1:]
+				break
+			}
+		}
+	}
+	return opResult
+}
+
+// containerLabels returns the labels of the operation, and the labels that
+// identify the operation of the container. Docker containers have no
+// annotations, so the annotations of the operation are not set.
+func containerLabels(op *driver.Operation) map[string]string {
+	labels := map[string]string{}
+	for k, v := range op.Labels {
+		if !strings.HasPrefix(k, "cnab.io/") {
+			labels[k] = v
+		}
+	}
+	labels[driver.LabelInstallation] = op.Installation
+	labels[driver.LabelRevision] = op.Revision
+	return labels
+}
+
+// Inspect reports the state of the container run for a revision of an
+// installation. Containers are only found until they are cleaned up, once
+// they exit.
+func (d *Driver) Inspect(installation, revision string) (driver.OperationStatus, error) {
+	cli, err := d.initializeDockerCli()
+	if err != nil {
+		return driver.OperationStatus{}, err
+	}
+
+	ctx := context.Background()
+	containers, err := cli.Client().ContainerList(ctx, types.ContainerListOptions{
+		All: true,
+		Filters: filters.NewArgs(
+			filters.Arg("label", driver.LabelInstallation+"="+installation),
+			filters.Arg("label", driver.LabelRevision+"="+revision),
+		),
+	})
+	if err != nil {
+		return driver.OperationStatus{}, err
+	}
+	if len(containers) == 0 {
+		return driver.OperationStatus{State: driver.OperationNotFound}, nil
+	}
+
+	info, err := cli.Client().ContainerInspect(ctx, containers[0].ID)
+	if err != nil {
+		return driver.OperationStatus{}, err
+	}
+	status := containerStatus(info.State)
+	status.OperationID = info.ID
+	return status, nil
+}
+
+// containerStatus returns the status of the operation run by a container in the
+// given state. Only containers that exited with a zero exit code succeeded: a
+// container that was created but never started has a zero exit code as well.
+func containerStatus(state *types.ContainerState) driver.OperationStatus {
+	if state == nil {
+		return driver.OperationStatus{State: driver.OperationFailed, Message: "the container has no state"}
+	}
+
+	var status driver.OperationStatus
+	switch state.Status {
+	case "running", "paused", "restarting":
+		status.State = driver.OperationRunning
+	case "created":
+		status.State = driver.OperationFailed
+		status.Message = "the container was created but never started"
+	case "exited", "removing":
+		exitCode := state.ExitCode
+		status.ExitCode = &exitCode
+		if exitCode == 0 && state.Error == "" {
+			status.State = driver.OperationSucceeded
+		} else {
+			status.State = driver.OperationFailed
+			status.Message = state.Error
+		}
+	default:
+		// "dead" containers, and states this driver does not know of.
+		status.State = driver.OperationFailed
+		status.Message = fmt.Sprintf("the container is %s", state.Status)
+		if state.Error != "" {
+			status.Message += ": " + state.Error
+		}
+	}
+	return status
+}
+
 func containerError(containerMessage string, containerErr, fetchErr error) error {
 	if fetchErr != nil {
 		return fmt.Errorf("%s: %v. fetching outputs failed: %s", containerMessage, containerErr, fetchErr)
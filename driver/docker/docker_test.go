@@ -0,0 +1,44 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cnabio/cnab-go/driver"
+)
+
+func TestContainerStatus(t *testing.T) {
+	zero, one := 0, 1
+	testcases := map[string]struct {
+		state *types.ContainerState
+		want  driver.OperationStatus
+	}{
+		"running": {
+			state: &types.ContainerState{Status: "running", Running: true},
+			want:  driver.OperationStatus{State: driver.OperationRunning},
+		},
+		"exited successfully": {
+			state: &types.ContainerState{Status: "exited"},
+			want:  driver.OperationStatus{State: driver.OperationSucceeded, ExitCode: &zero},
+		},
+		"exited with an error": {
+			state: &types.ContainerState{Status: "exited", ExitCode: 1},
+			want:  driver.OperationStatus{State: driver.OperationFailed, ExitCode: &one},
+		},
+		"created but never started": {
+			state: &types.ContainerState{Status: "created"},
+			want:  driver.OperationStatus{State: driver.OperationFailed, Message: "the container was created but never started"},
+		},
+		"dead": {
+			state: &types.ContainerState{Status: "dead", Error: "driver failed"},
+			want:  driver.OperationStatus{State: driver.OperationFailed, Message: "the container is dead: driver failed"},
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, containerStatus(tc.state))
+		})
+	}
+}
@@ -38,6 +38,11 @@ type Operation struct {
 	Out io.Writer `json:"-"`
 	// Bundle represents the bundle information for use by the operation
 	Bundle *bundle.Bundle
+	// Labels and Annotations of the installation, that drivers set on the
+	// resources they run the operation with. Keys prefixed with "cnab.io/" are
+	// reserved for the drivers.
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
 // ResolvedCred is a credential that has been resolved and is ready for injection into the runtime.
@@ -51,6 +56,58 @@ type ResolvedCred struct {
 type OperationResult struct {
 	// Outputs is a map from the container path of an output file to its contents (i.e. /cnab/app/outputs/...).
 	Outputs map[string]string
+	// Driver is the name of the driver that ran the operation.
+	Driver string
+	// OperationID identifies the resources the driver ran the operation with,
+	// such as the name of a Kubernetes job or the ID of a Docker container.
+	OperationID string
+	// ImageDigest is the digest of the invocation image that was run, when the
+	// driver can tell.
+	ImageDigest string
+	// ExitCode is the exit code of the invocation image, when the driver can tell.
+	ExitCode *int
+}
+
+// Labels that drivers set on the resources they run operations with, so that
+// the operations can be found again, for example by Inspect.
+const (
+	LabelInstallation = "cnab.io/installation"
+	LabelRevision     = "cnab.io/revision"
+)
+
+// OperationState is the state of an operation started by a driver.
+type OperationState string
+
+const (
+	// OperationRunning is the state of an operation that is still running.
+	OperationRunning OperationState = "running"
+	// OperationSucceeded is the state of an operation that completed successfully.
+	OperationSucceeded OperationState = "succeeded"
+	// OperationFailed is the state of an operation that failed.
+	OperationFailed OperationState = "failed"
+	// OperationNotFound is the state of an operation the driver has no trace of,
+	// because it never started or its resources were cleaned up.
+	OperationNotFound OperationState = "notfound"
+)
+
+// OperationStatus is the status of an operation started by a driver.
+type OperationStatus struct {
+	State OperationState
+	// OperationID identifies the resources of the operation, as in OperationResult.
+	OperationID string
+	// ExitCode is the exit code of the invocation image, when it exited and
+	// the driver can tell.
+	ExitCode *int
+	// Message describes the state, such as the reason of a failure.
+	Message string
+}
+
+// Inspector is implemented by drivers that can report the state of the
+// operations they started, including from another process.
+type Inspector interface {
+	// Inspect reports the state of the operation run for a revision of an
+	// installation.
+	Inspect(installation, revision string) (OperationStatus, error)
 }
 
 // Driver is capable of running a invocation image
@@ -84,7 +141,7 @@ func (d *DebugDriver) Run(op *Operation) (OperationResult, error) {
 		return OperationResult{}, err
 	}
 	fmt.Fprintln(op.Out, string(data))
-	return OperationResult{}, nil
+	return OperationResult{Driver: "debug"}, nil
 }
 
 // Handles always returns true, effectively claiming to work for any image type
@@ -0,0 +1,33 @@
+package driver
+
+import "errors"
+
+// NotStartedError indicates that a driver failed before the invocation image
+// started running, for example while pulling the image or creating the
+// container. The bundle did not execute, so the operation is safe to retry.
+type NotStartedError struct {
+	Err error
+}
+
+// NotStarted wraps err in a NotStartedError. It returns nil when err is nil.
+func NotStarted(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &NotStartedError{Err: err}
+}
+
+func (e *NotStartedError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *NotStartedError) Unwrap() error {
+	return e.Err
+}
+
+// IsNotStarted reports whether err, or any error it wraps, is a NotStartedError.
+func IsNotStarted(err error) bool {
+	var nse *NotStartedError
+	return errors.As(err, &nse)
+}
@@ -0,0 +1,25 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotStarted(t *testing.T) {
+	is := assert.New(t)
+
+	is.Nil(NotStarted(nil))
+
+	cause := errors.New("image pull failed")
+	err := NotStarted(cause)
+	is.EqualError(err, "image pull failed")
+	is.True(IsNotStarted(err))
+	is.True(errors.Is(err, cause))
+	is.True(IsNotStarted(fmt.Errorf("install: %w", err)), "wrapped errors should be detected")
+
+	is.False(IsNotStarted(cause))
+	is.False(IsNotStarted(nil))
+}
@@ -15,6 +15,7 @@ import (
 
 	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -139,10 +140,8 @@ func (k *Driver) Run(op *driver.Operation) (driver.OperationResult, error) {
 	meta := metav1.ObjectMeta{
 		Namespace:    k.Namespace,
 		GenerateName: generateNameTemplate(op),
-		Labels: map[string]string{
-			"cnab.io/driver": "kubernetes",
-		},
-		Annotations: generateMergedAnnotations(op, k.Annotations),
+		Labels:       generateMergedLabels(op),
+		Annotations:  generateMergedAnnotations(op, k.Annotations, op.Annotations),
 	}
 	// Mount SA token if a non-zero value for ServiceAccountName has been specified
 	mountServiceAccountToken := k.ServiceAccountName != ""
@@ -187,7 +186,7 @@ func (k *Driver) Run(op *driver.Operation) (driver.OperationResult, error) {
 		secret.ObjectMeta.GenerateName += "env-"
 		secret, err := k.secrets.Create(secret)
 		if err != nil {
-			return driver.OperationResult{}, err
+			return driver.OperationResult{}, notStarted(err)
 		}
 		if !k.SkipCleanup {
 			defer k.deleteSecret(secret.ObjectMeta.Name)
@@ -210,7 +209,7 @@ func (k *Driver) Run(op *driver.Operation) (driver.OperationResult, error) {
 		secret.ObjectMeta.GenerateName += "files-"
 		secret, err := k.secrets.Create(secret)
 		if err != nil {
-			return driver.OperationResult{}, err
+			return driver.OperationResult{}, notStarted(err)
 		}
 		if !k.SkipCleanup {
 			defer k.deleteSecret(secret.ObjectMeta.Name)
@@ -230,16 +229,27 @@ func (k *Driver) Run(op *driver.Operation) (driver.OperationResult, error) {
 	job.Spec.Template.Spec.Containers = []v1.Container{container}
 	job, err := k.jobs.Create(job)
 	if err != nil {
+		// The job may have been created despite a timeout or a server error,
+		// and retrying would run the bundle twice. Only a throttled request is
+		// known to have been rejected.
+		if apierrors.IsTooManyRequests(err) {
+			err = driver.NotStarted(err)
+		}
 		return driver.OperationResult{}, err
 	}
 	if !k.SkipCleanup {
 		defer k.deleteJob(job.ObjectMeta.Name)
 	}
 
+	opResult := driver.OperationResult{
+		Driver:      "kubernetes",
+		OperationID: job.ObjectMeta.Name,
+	}
+
 	// Return early for unit testing purposes (the fake k8s client implementation just
 	// hangs during watch because no events are ever created on the Job)
 	if k.skipJobStatusCheck {
-		return driver.OperationResult{}, nil
+		return opResult, nil
 	}
 
 	// Create a selector to detect the job just created
@@ -253,7 +263,82 @@ func (k *Driver) Run(op *driver.Operation) (driver.OperationResult, error) {
 		LabelSelector: newSingleFieldSelector("job-name", job.ObjectMeta.Name),
 	}
 
-	return driver.OperationResult{}, k.watchJobStatusAndLogs(podSelector, jobSelector, op.Out)
+	err = k.watchJobStatusAndLogs(podSelector, jobSelector, op.Out)
+	k.describePod(&opResult, podSelector)
+	return opResult, err
+}
+
+// describePod records the exit code of the invocation image, and the digest of
+// the image that was run, from the status of the most recent pod of the job.
+func (k *Driver) describePod(opResult *driver.OperationResult, podSelector metav1.ListOptions) {
+	pods, err := k.pods.List(podSelector)
+	if err != nil || len(pods.Items) == 0 {
+		return
+	}
+	latest := pods.Items[0]
+	for _, pod := range pods.Items[1:] {
+		if latest.CreationTimestamp.Before(&pod.CreationTimestamp) {
+			latest = pod
+		}
+	}
+
+	for _, status := range latest.Status.ContainerStatuses {
+		if status.Name != k8sContainerName {
+			continue
+		}
+		opResult.ImageDigest = imageIDDigest(status.ImageID)
+		if terminated := status.State.Terminated; terminated != nil {
+			exitCode := int(terminated.ExitCode)
+			opResult.ExitCode = &exitCode
+		}
+	}
+}
+
+// imageIDDigest returns the digest of the image ID of a container status, such
+// as docker-pullable://cnab/helloworld@sha256:..., if any.
+func imageIDDigest(imageID string) string {
+	i := strings.LastIndex(imageID, "@")
+	if i < 0 {
+		return ""
+	}
+	return imageID[i+1:]
+}
+
+// Inspect reports the state of the job run for a revision of an installation.
+// Jobs are only found until they are cleaned up, once they complete.
+func (k *Driver) Inspect(installation, revision string) (driver.OperationStatus, error) {
+	jobs, err := k.jobs.List(metav1.ListOptions{
+		LabelSelector: newSingleFieldSelector(driver.LabelRevision, revision),
+	})
+	if err != nil {
+		return driver.OperationStatus{}, err
+	}
+	for _, job := range jobs.Items {
+		if job.Annotations["cnab.io/installation"] != installation {
+			continue
+		}
+
+		status := driver.OperationStatus{State: driver.OperationRunning, OperationID: job.Name}
+		for _, cond := range job.Status.Conditions {
+			if cond.Status != v1.ConditionTrue {
+				continue
+			}
+			switch cond.Type {
+			case batchv1.JobFailed:
+				status.State = driver.OperationFailed
+				status.Message = cond.Message
+			case batchv1.JobComplete:
+				status.State = driver.OperationSucceeded
+			}
+		}
+		if status.State != driver.OperationRunning {
+			var opResult driver.OperationResult
+			k.describePod(&opResult, metav1.ListOptions{LabelSelector: newSingleFieldSelector("job-name", job.Name)})
+			status.ExitCode = opResult.ExitCode
+		}
+		return status, nil
+	}
+	return driver.OperationStatus{State: driver.OperationNotFound}, nil
 }
 
 func (k *Driver) watchJobStatusAndLogs(podSelector metav1.ListOptions, jobSelector metav1.ListOptions, out io.Writer) error {
@@ -355,6 +440,21 @@ func (k *Driver) streamPodLogs(options metav1.ListOptions, out io.Writer, done c
 	return nil
 }
 
+// notStarted wraps the errors of the requests creating the secrets of the job
+// in driver.NotStarted when they are transient, so that the operation is
+// retried. Other errors, such as a forbidden or invalid request, would fail
+// again. A secret created despite the error is harmless, since each attempt
+// generates new names.
+func notStarted(err error) error {
+	if apierrors.IsTimeout(err) || apierrors.IsServerTimeout(err) || apierrors.IsTooManyRequests(err) {
+		return driver.NotStarted(err)
+	}
+	if status, ok := err.(apierrors.APIStatus); ok && status.Status().Code >= 500 {
+		return driver.NotStarted(err)
+	}
+	return err
+}
+
 func (k *Driver) deleteSecret(name string) error {
 	return k.secrets.Delete(name, &metav1.DeleteOptions{
 		PropagationPolicy: &k.deletionPolicy,
@@ -390,19 +490,40 @@ func generateNameTemplate(op *driver.Operation) string {
 	return result
 }
 
-func generateMergedAnnotations(op *driver.Operation, mergeWith map[string]string) map[string]string {
+func generateMergedLabels(op *driver.Operation) map[string]string {
+	labels := map[string]string{
+		"cnab.io/driver":     "kubernetes",
+		driver.LabelRevision: op.Revision,
+	}
+
+	for k, v := range op.Labels {
+		if strings.HasPrefix(k, cnabPrefix) {
+			log.Printf("Labels with prefix '%s' are reserved. Label '%s: %s' will not be applied.\n", cnabPrefix, k, v)
+			continue
+		}
+		labels[k] = v
+	}
+
+	return labels
+}
+
+// generateMergedAnnotations merges the annotations into the annotations
+// reserved by the driver, with the later ones taking precedence.
+func generateMergedAnnotations(op *driver.Operation, mergeWith ...map[string]string) map[string]string {
 	anno := map[string]string{
 		"cnab.io/installation": op.Installation,
 		"cnab.io/action":       op.Action,
 		"cnab.io/revision":     op.Revision,
 	}
 
-	for k, v := range mergeWith {
-		if strings.HasPrefix(k, cnabPrefix) {
-			log.Printf("Annotations with prefix '%s' are reserved. Annotation '%s: %s' will not be applied.\n", cnabPrefix, k, v)
-			continue
+	for _, annotations := range mergeWith {
+		for k, v := range annotations {
+			if strings.HasPrefix(k, cnabPrefix) {
+				log.Printf("Annotations with prefix '%s' are reserved. Annotation '%s: %s' will not be applied.\n", cnabPrefix, k, v)
+				continue
+			}
+			anno[k] = v
 		}
-		anno[k] = v
 	}
 
 	return anno
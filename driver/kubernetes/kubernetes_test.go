@@ -1,14 +1,23 @@
 package kubernetes
 
 import (
+	"errors"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/cnabio/cnab-go/bundle"
 	"github.com/cnabio/cnab-go/driver"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestDriver_Run(t *testing.T) {
@@ -28,18 +37,170 @@ func TestDriver_Run(t *testing.T) {
 		Environment: map[string]string{
 			"foo": "bar",
 		},
+		Labels:      map[string]string{"team": "platform", "cnab.io/driver": "other"},
+		Annotations: map[string]string{"owner": "alice@example.com"},
 	}
 
-	_, err := k.Run(&op)
+	opResult, err := k.Run(&op)
 	assert.NoError(t, err)
+	assert.Equal(t, "kubernetes", opResult.Driver)
 
 	jobList, _ := k.jobs.List(metav1.ListOptions{})
 	assert.Equal(t, len(jobList.Items), 1, "expected one job to be created")
+	job := jobList.Items[0]
+	assert.Equal(t, "platform", job.Labels["team"])
+	assert.Equal(t, "kubernetes", job.Labels["cnab.io/driver"], "reserved labels should not be overridden")
+	assert.Equal(t, "alice@example.com", job.Annotations["owner"])
+	assert.Equal(t, "platform", job.Spec.Template.Labels["team"])
 
 	secretList, _ := k.secrets.List(metav1.ListOptions{})
 	assert.Equal(t, len(secretList.Items), 1, "expected one secret to be created")
 }
 
+func TestDriver_RunNotStarted(t *testing.T) {
+	jobs := schema.GroupResource{Group: "batch", Resource: "jobs"}
+	testcases := map[string]struct {
+		resource   string
+		err        error
+		notStarted bool
+	}{
+		"secret: service unavailable": {"secrets", apierrors.NewServiceUnavailable("overloaded"), true},
+		"secret: internal error":      {"secrets", apierrors.NewInternalError(errors.New("boom")), true},
+		"secret: timeout":             {"secrets", apierrors.NewServerTimeout(jobs, "create", 1), true},
+		"secret: forbidden":           {"secrets", apierrors.NewForbidden(jobs, "", errors.New("denied")), false},
+		// The job may exist despite these errors.
+		"job: service unavailable": {"jobs", apierrors.NewServiceUnavailable("overloaded"), false},
+		"job: internal error":      {"jobs", apierrors.NewInternalError(errors.New("boom")), false},
+		"job: timeout":             {"jobs", apierrors.NewServerTimeout(jobs, "create", 1), false},
+		"job: too many requests":   {"jobs", apierrors.NewTooManyRequests("slow down", 1), true},
+		"job: forbidden":           {"jobs", apierrors.NewForbidden(jobs, "", errors.New("denied")), false},
+		"job: invalid":             {"jobs", apierrors.NewInvalid(schema.GroupKind{Group: "batch", Kind: "Job"}, "", nil), false},
+		"job: not an API error":    {"jobs", errors.New("boom"), false},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			client.PrependReactor("create", tc.resource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, tc.err
+			})
+			k := Driver{
+				Namespace:          "default",
+				jobs:               client.BatchV1().Jobs("default"),
+				secrets:            client.CoreV1().Secrets("default"),
+				pods:               client.CoreV1().Pods("default"),
+				SkipCleanup:        true,
+				skipJobStatusCheck: true,
+			}
+
+			op := &driver.Operation{Action: "install", Out: os.Stdout, Environment: map[string]string{"foo": "bar"}}
+			_, err := k.Run(op)
+			require.Error(t, err)
+			assert.Equal(t, tc.notStarted, driver.IsNotStarted(err))
+			assert.True(t, errors.Is(err, tc.err))
+		})
+	}
+}
+
+func TestDriver_DescribePod(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	namespace := "default"
+	k := Driver{
+		Namespace: namespace,
+		pods:      client.CoreV1().Pods(namespace),
+	}
+
+	pod := func(name string, created time.Time, exitCode int32) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         namespace,
+				Labels:            map[string]string{"job-name": "install-test-abcde"},
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Status: v1.PodStatus{
+				ContainerStatuses: []v1.ContainerStatus{
+					{
+						Name:    k8sContainerName,
+						ImageID: "docker-pullable://cnab/helloworld@sha256:" + name,
+						State: v1.ContainerState{
+							Terminated: &v1.ContainerStateTerminated{ExitCode: exitCode},
+						},
+					},
+				},
+			},
+		}
+	}
+	now := time.Now()
+	_, err := k.pods.Create(pod("first", now.Add(-time.Minute), 1))
+	require.NoError(t, err)
+	_, err = k.pods.Create(pod("retry", now, 0))
+	require.NoError(t, err)
+
+	var opResult driver.OperationResult
+	k.describePod(&opResult, metav1.ListOptions{LabelSelector: newSingleFieldSelector("job-name", "install-test-abcde")})
+	assert.Equal(t, "sha256:retry", opResult.ImageDigest)
+	if assert.NotNil(t, opResult.ExitCode) {
+		assert.Equal(t, 0, *opResult.ExitCode)
+	}
+}
+
+func TestDriver_Inspect(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	namespace := "default"
+	k := Driver{
+		Namespace: namespace,
+		jobs:      client.BatchV1().Jobs(namespace),
+		pods:      client.CoreV1().Pods(namespace),
+	}
+
+	job := func(name, installation, revision string, conditions ...batchv1.JobCondition) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   namespace,
+				Labels:      map[string]string{driver.LabelRevision: revision},
+				Annotations: map[string]string{"cnab.io/installation": installation},
+			},
+			Status: batchv1.JobStatus{Conditions: conditions},
+		}
+	}
+	_, err := k.jobs.Create(job("install-running", "test", "01"))
+	require.NoError(t, err)
+	_, err = k.jobs.Create(job("install-complete", "test", "02",
+		batchv1.JobCondition{Type: batchv1.JobComplete, Status: v1.ConditionTrue}))
+	require.NoError(t, err)
+	_, err = k.jobs.Create(job("install-failed", "test", "03",
+		batchv1.JobCondition{Type: batchv1.JobFailed, Status: v1.ConditionTrue, Message: "BackoffLimitExceeded"}))
+	require.NoError(t, err)
+
+	status, err := k.Inspect("test", "01")
+	require.NoError(t, err)
+	assert.Equal(t, driver.OperationStatus{State: driver.OperationRunning, OperationID: "install-running"}, status)
+
+	status, err = k.Inspect("test", "02")
+	require.NoError(t, err)
+	assert.Equal(t, driver.OperationSucceeded, status.State)
+	assert.Equal(t, "install-complete", status.OperationID)
+
+	status, err = k.Inspect("test", "03")
+	require.NoError(t, err)
+	assert.Equal(t, driver.OperationFailed, status.State)
+	assert.Equal(t, "BackoffLimitExceeded", status.Message)
+
+	status, err = k.Inspect("other", "01")
+	require.NoError(t, err)
+	assert.Equal(t, driver.OperationNotFound, status.State, "jobs of another installation are not matched")
+
+	status, err = k.Inspect("test", "04")
+	require.NoError(t, err)
+	assert.Equal(t, driver.OperationNotFound, status.State)
+}
+
+func TestImageIDDigest(t *testing.T) {
+	assert.Equal(t, "sha256:a1b2c3", imageIDDigest("docker-pullable://cnab/helloworld@sha256:a1b2c3"))
+	assert.Equal(t, "", imageIDDigest("sha256:a1b2c3"))
+}
+
 func TestImageWithDigest(t *testing.T) {
 	testCases := map[string]bundle.InvocationImage{
 		"foo": {
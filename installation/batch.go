@@ -0,0 +1,185 @@
+package installation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cnabio/cnab-go/claim"
+)
+
+// ErrBatchTimeout is returned for the installations whose task did not
+// complete within Batch.Timeout.
+var ErrBatchTimeout = errors.New("timed out")
+
+// BatchTask runs against one installation of a batch, for example by calling
+// Manager.Upgrade with the name of the claim.
+//
+// Tasks should return once ctx is done. A task that ignores ctx is reported as
+// timed out, but it keeps its worker until it returns, so that no more tasks
+// than Batch.Concurrency ever run at the same time: Manager and the drivers do
+// not take a context, so tasks built on them cannot be interrupted.
+type BatchTask func(ctx context.Context, c claim.Claim) (*claim.Claim, error)
+
+// Batch runs a task against many installations with a pool of workers.
+type Batch struct {
+	// Concurrency is the number of tasks that run at the same time. Tasks run
+	// one at a time when it is not set.
+	Concurrency int
+	// Timeout limits the duration of each task. Tasks that run longer are
+	// reported with ErrBatchTimeout, and count as failed for StopOnFailure.
+	// The batch still waits for them to return. Tasks are not limited when it
+	// is not set.
+	Timeout time.Duration
+	// StopOnFailure stops starting new tasks once a task failed. The tasks
+	// already running are allowed to complete, and the remaining installations
+	// are reported as skipped.
+	StopOnFailure bool
+	// Progress, when set, is called once per installation as its result is
+	// known. Calls are never concurrent.
+	Progress func(BatchProgress)
+}
+
+// BatchResult is the outcome of the task for one installation.
+type BatchResult struct {
+	Installation string
+	// Claim is the claim returned by the task.
+	Claim *claim.Claim
+	// Err is the error returned by the task, or ErrBatchTimeout.
+	Err error
+	// Skipped is true when the task was never started.
+	Skipped  bool
+	Started  time.Time
+	Duration time.Duration
+}
+
+// BatchProgress reports the progress of a batch.
+type BatchProgress struct {
+	Total     int
+	Completed int
+	Failed    int
+	Skipped   int
+	// Last is the result that triggered this report.
+	Last BatchResult
+}
+
+// Run runs the task against every claim, and returns the results in the order
+// of the claims. Cancelling ctx stops starting new tasks, and is passed on to
+// the running ones.
+func (b Batch) Run(ctx context.Context, claims []claim.Claim, task BatchTask) []BatchResult {
+	results := make([]BatchResult, len(claims))
+	ran := make([]bool, len(claims))
+
+	dispatch, stop := context.WithCancel(ctx)
+	defer stop()
+
+	var mu sync.Mutex
+	progress := BatchProgress{Total: len(claims)}
+	report := func(res BatchResult) {
+		switch {
+		case res.Skipped:
+			progress.Skipped++
+		case res.Err != nil:
+			progress.Failed++
+		default:
+			progress.Completed++
+		}
+		progress.Last = res
+		if b.Progress != nil {
+			b.Progress(progress)
+		}
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < b.concurrency(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if dispatch.Err() != nil {
+					// Stopped while the job was handed over.
+					continue
+				}
+				res := b.runOne(ctx, claims[i], task)
+
+				mu.Lock()
+				results[i] = res
+				ran[i] = true
+				if res.Err != nil && b.StopOnFailure {
+					stop()
+				}
+				report(res)
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for i := range claims {
+		if dispatch.Err() != nil {
+			break
+		}
+		select {
+		case jobs <- i:
+		case <-dispatch.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, c := range claims {
+		if !ran[i] {
+			results[i] = BatchResult{Installation: c.Name, Skipped: true}
+			report(results[i])
+		}
+	}
+	return results
+}
+
+func (b Batch) concurrency() int {
+	if b.Concurrency > 0 {
+		return b.Concurrency
+	}
+	return 1
+}
+
+func (b Batch) runOne(ctx context.Context, c claim.Claim, task BatchTask) BatchResult {
+	res := BatchResult{Installation: c.Name, Started: time.Now()}
+
+	taskCtx, cancel := ctx, context.CancelFunc(func() {})
+	if b.Timeout > 0 {
+		taskCtx, cancel = context.WithTimeout(ctx, b.Timeout)
+	}
+	defer cancel()
+
+	type outcome struct {
+		claim *claim.Claim
+		err   error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		updated, err := task(taskCtx, c)
+		done <- outcome{updated, err}
+	}()
+
+	select {
+	case o := <-done:
+		res.Claim, res.Err = o.claim, o.err
+	case <-taskCtx.Done():
+		if taskCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			res.Err = fmt.Errorf("installation %q %w after %s", c.Name, ErrBatchTimeout, b.Timeout)
+		} else {
+			res.Err = taskCtx.Err()
+		}
+		// Hold the worker until the task returns, so that tasks ignoring ctx
+		// do not pile up beyond the concurrency of the batch.
+		o := <-done
+		res.Claim = o.claim
+	}
+	res.Duration = time.Since(res.Started)
+	return res
+}
@@ -0,0 +1,183 @@
+package installation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cnabio/cnab-go/claim"
+)
+
+func batchClaims(names ...string) []claim.Claim {
+	claims := make([]claim.Claim, len(names))
+	for i, name := range names {
+		claims[i] = claim.Claim{Name: name}
+	}
+	return claims
+}
+
+func TestBatch_Run(t *testing.T) {
+	t.Run("bounded concurrency", func(t *testing.T) {
+		var mu sync.Mutex
+		running, maxRunning := 0, 0
+		task := func(ctx context.Context, c claim.Claim) (*claim.Claim, error) {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return &c, nil
+		}
+
+		var reports []BatchProgress
+		b := Batch{Concurrency: 2, Progress: func(p BatchProgress) { reports = append(reports, p) }}
+		results := b.Run(context.Background(), batchClaims("a", "b", "c", "d", "e"), task)
+
+		require.Len(t, results, 5)
+		for i, name := range []string{"a", "b", "c", "d", "e"} {
+			assert.Equal(t, name, results[i].Installation)
+			assert.Equal(t, name, results[i].Claim.Name)
+			assert.NoError(t, results[i].Err)
+		}
+		assert.Equal(t, 2, maxRunning)
+		require.Len(t, reports, 5)
+		assert.Equal(t, 5, reports[4].Total)
+		assert.Equal(t, 5, reports[4].Completed)
+		assert.Equal(t, 0, reports[4].Failed)
+	})
+
+	t.Run("keep going after a failure", func(t *testing.T) {
+		task := func(ctx context.Context, c claim.Claim) (*claim.Claim, error) {
+			if c.Name == "b" {
+				return nil, errors.New("boom")
+			}
+			return &c, nil
+		}
+		results := Batch{}.Run(context.Background(), batchClaims("a", "b", "c"), task)
+		assert.NoError(t, results[0].Err)
+		assert.EqualError(t, results[1].Err, "boom")
+		assert.NoError(t, results[2].Err)
+	})
+
+	t.Run("stop on first failure", func(t *testing.T) {
+		var last BatchProgress
+		task := func(ctx context.Context, c claim.Claim) (*claim.Claim, error) {
+			if c.Name == "b" {
+				return nil, errors.New("boom")
+			}
+			return &c, nil
+		}
+		b := Batch{StopOnFailure: true, Progress: func(p BatchProgress) { last = p }}
+		results := b.Run(context.Background(), batchClaims("a", "b", "c", "d"), task)
+		assert.NoError(t, results[0].Err)
+		assert.EqualError(t, results[1].Err, "boom")
+		assert.True(t, results[2].Skipped)
+		assert.True(t, results[3].Skipped)
+		assert.Equal(t, 1, last.Completed)
+		assert.Equal(t, 1, last.Failed)
+		assert.Equal(t, 2, last.Skipped)
+	})
+
+	t.Run("per-item timeout", func(t *testing.T) {
+		task := func(ctx context.Context, c claim.Claim) (*claim.Claim, error) {
+			if c.Name == "slow" {
+				<-ctx.Done()
+				time.Sleep(50 * time.Millisecond)
+			}
+			return &c, nil
+		}
+		results := Batch{Timeout: 10 * time.Millisecond}.Run(context.Background(), batchClaims("slow", "fast"), task)
+		assert.True(t, errors.Is(results[0].Err, ErrBatchTimeout))
+		assert.True(t, results[0].Duration >= 10*time.Millisecond)
+		assert.EqualError(t, results[0].Err, `installation "slow" timed out after 10ms`)
+		assert.NoError(t, results[1].Err)
+	})
+
+	t.Run("tasks ignoring the timeout keep their worker", func(t *testing.T) {
+		var mu sync.Mutex
+		running, maxRunning, returned := 0, 0, 0
+		task := func(ctx context.Context, c claim.Claim) (*claim.Claim, error) {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+			// Like Manager, ignore ctx.
+			time.Sleep(30 * time.Millisecond)
+			mu.Lock()
+			running--
+			returned++
+			mu.Unlock()
+			return &c, nil
+		}
+		b := Batch{Concurrency: 2, Timeout: 5 * time.Millisecond}
+		results := b.Run(context.Background(), batchClaims("a", "b", "c", "d"), task)
+		for _, res := range results {
+			assert.True(t, errors.Is(res.Err, ErrBatchTimeout))
+			assert.True(t, res.Duration >= 30*time.Millisecond, "the duration should cover the whole task")
+		}
+		assert.Equal(t, 2, maxRunning, "timed out tasks should not let more tasks run")
+		assert.Equal(t, 4, returned, "the batch should wait for timed out tasks")
+	})
+
+	t.Run("stop on timeout", func(t *testing.T) {
+		task := func(ctx context.Context, c claim.Claim) (*claim.Claim, error) {
+			time.Sleep(20 * time.Millisecond)
+			return &c, nil
+		}
+		b := Batch{Timeout: 5 * time.Millisecond, StopOnFailure: true}
+		results := b.Run(context.Background(), batchClaims("a", "b"), task)
+		assert.True(t, errors.Is(results[0].Err, ErrBatchTimeout))
+		assert.True(t, results[1].Skipped)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		results := Batch{}.Run(ctx, batchClaims("a", "b"), func(ctx context.Context, c claim.Claim) (*claim.Claim, error) {
+			return &c, nil
+		})
+		for _, res := range results {
+			assert.True(t, res.Skipped)
+		}
+	})
+}
+
+func TestBatch_WithManager(t *testing.T) {
+	m, _, cleanup := newTestManager(t)
+	defer cleanup()
+
+	for _, name := range []string{"a", "b"} {
+		_, err := m.Install(name, mockBundle("0.1.0"), nil, discard)
+		require.NoError(t, err)
+	}
+
+	outdated, err := BundleVersion("< 0.2.0")
+	require.NoError(t, err)
+	claims, err := Select(m.Claims, Installed(), ForBundle("mybun"), outdated)
+	require.NoError(t, err)
+	require.Len(t, claims, 2)
+
+	upgrade := func(ctx context.Context, c claim.Claim) (*claim.Claim, error) {
+		return m.Upgrade(c.Name, mockBundle("0.2.0"), nil, discard)
+	}
+	for _, res := range (Batch{}).Run(context.Background(), claims, upgrade) {
+		require.NoError(t, res.Err)
+		assert.Equal(t, "0.2.0", res.Claim.Bundle.Version)
+	}
+
+	claims, err = Select(m.Claims, outdated)
+	require.NoError(t, err)
+	assert.Empty(t, claims)
+}
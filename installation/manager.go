@@ -0,0 +1,311 @@
+// Package installation manages the lifecycle of CNAB installations, persisting
+// their claims around each action.
+package installation
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/cnabio/cnab-go/action"
+	"github.com/cnabio/cnab-go/bundle"
+	"github.com/cnabio/cnab-go/claim"
+	"github.com/cnabio/cnab-go/credentials"
+	"github.com/cnabio/cnab-go/driver"
+)
+
+// ErrInstallationExists indicates that an installation with the same name already exists.
+var ErrInstallationExists = errors.New("installation already exists")
+
+// Manager runs actions against installations and owns the persistence of
+// their claims.
+//
+// For every action that modifies an installation, the claim is saved with an
+// underway status right before the driver runs, and saved again with the
+// final result afterwards, so that the stored state never lags behind what
+// was executed. Claims left underway by a process that stopped meanwhile are
+// completed by Recover.
+//
+// When Options.Locks is set, the installation is locked from the moment its
+// claim is read until the final claim is saved, for the actions that modify
+// it.
+type Manager struct {
+	Driver      driver.Driver
+	Claims      claim.Store
+	Credentials credentials.Set
+	// Options are applied to the actions created by the manager.
+	Options action.Options
+}
+
+// Install installs the bundle under the given installation name.
+//
+// It fails with ErrInstallationExists when the name is already used by an
+// installation that was not uninstalled.
+func (m *Manager) Install(name string, b *bundle.Bundle, params map[string]interface{}, opCfgs ...action.OperationConfigFunc) (_ *claim.Claim, err error) {
+	unlock, err := m.Options.Lock(name)
+	if err != nil {
+		return nil, err
+	}
+	defer release(unlock, &err)
+
+	existing, err := m.Claims.Read(name)
+	switch {
+	case err == nil:
+		if !isUninstalled(existing) {
+			return nil, fmt.Errorf("cannot install %q: %w", name, ErrInstallationExists)
+		}
+	case err != claim.ErrClaimNotFound:
+		return nil, err
+	}
+
+	c, err := claim.New(name)
+	if err != nil {
+		return nil, err
+	}
+	c.Bundle = b
+	if params != nil {
+		c.Parameters = params
+	}
+
+	inst := &action.Install{Driver: m.Driver, Options: m.actionOptions()}
+	return c, m.run(c, inst, true, "", opCfgs)
+}
+
+// Upgrade upgrades an existing installation to the given bundle. When params
+// is nil, the parameters of the installation are kept.
+func (m *Manager) Upgrade(name string, b *bundle.Bundle, params map[string]interface{}, opCfgs ...action.OperationConfigFunc) (_ *claim.Claim, err error) {
+	unlock, err := m.Options.Lock(name)
+	if err != nil {
+		return nil, err
+	}
+	defer release(unlock, &err)
+
+	c, err := m.load(name)
+	if err != nil {
+		return nil, err
+	}
+	c.Bundle = b
+	if params != nil {
+		c.Parameters = params
+	}
+
+	upgr := &action.Upgrade{Driver: m.Driver, Options: m.actionOptions()}
+	return c, m.run(c, upgr, true, "", opCfgs)
+}
+
+// Downgrade downgrades an existing installation to an older version of its
+// bundle. When params is nil, the parameters of the installation are kept.
+func (m *Manager) Downgrade(name string, b *bundle.Bundle, params map[string]interface{}, opCfgs ...action.OperationConfigFunc) (_ *claim.Claim, err error) {
+	unlock, err := m.Options.Lock(name)
+	if err != nil {
+		return nil, err
+	}
+	defer release(unlock, &err)
+
+	c, err := m.load(name)
+	if err != nil {
+		return nil, err
+	}
+	if params != nil {
+		c.Parameters = params
+	}
+
+	dg := &action.Downgrade{Driver: m.Driver, Bundle: b, Options: m.actionOptions()}
+	return c, m.run(c, dg, true, "", opCfgs)
+}
+
+// Uninstall uninstalls an existing installation. The claim is kept in the
+// store, recording the uninstall.
+func (m *Manager) Uninstall(name string, opCfgs ...action.OperationConfigFunc) (_ *claim.Claim, err error) {
+	unlock, err := m.Options.Lock(name)
+	if err != nil {
+		return nil, err
+	}
+	defer release(unlock, &err)
+
+	c, err := m.load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	uninst := &action.Uninstall{Driver: m.Driver, Options: m.actionOptions()}
+	return c, m.run(c, uninst, true, "", opCfgs)
+}
+
+// Run runs an arbitrary action against an existing installation.
+//
+// The claim is only persisted when the action modifies the installation: status
+// actions and custom actions that are not declared as modifying are never saved.
+// The manager holds the lock of the installation while an action that modifies
+// it runs, so the action should not be given Options.Locks itself. Read-only
+// actions run without the lock, alongside other actions.
+func (m *Manager) Run(name string, a action.Action, opCfgs ...action.OperationConfigFunc) (_ *claim.Claim, err error) {
+	c, err := m.load(name)
+	if err != nil {
+		return nil, err
+	}
+	if !modifies(a, c.Bundle) {
+		return c, m.run(c, a, false, "", opCfgs)
+	}
+
+	unlock, err := m.Options.Lock(name)
+	if err != nil {
+		return nil, err
+	}
+	defer release(unlock, &err)
+
+	// Read the claim again, since it may have changed before the lock was taken.
+	c, err = m.load(name)
+	if err != nil {
+		return nil, err
+	}
+	return c, m.run(c, a, true, "", opCfgs)
+}
+
+// Rollback restores the bundle and parameters of an earlier revision of an
+// installation. The installation is downgraded when the bundle version of the
+// revision is lower than the installed one, and upgraded otherwise. The new
+// revision records the revision it restored in Result.RestoredRevision.
+func (m *Manager) Rollback(name string, revision string, opCfgs ...action.OperationConfigFunc) (_ *claim.Claim, err error) {
+	unlock, err := m.Options.Lock(name)
+	if err != nil {
+		return nil, err
+	}
+	defer release(unlock, &err)
+
+	c, err := m.load(name)
+	if err != nil {
+		return nil, err
+	}
+	if revision == c.Revision {
+		return nil, fmt.Errorf("cannot roll back installation %q to revision %s, which is its current revision", name, revision)
+	}
+	restored, err := m.Claims.ReadRevision(name, revision)
+	if err != nil {
+		if err == claim.ErrClaimNotFound {
+			return nil, fmt.Errorf("revision %s of installation %q: %w", revision, name, claim.ErrClaimNotFound)
+		}
+		return nil, err
+	}
+	if restored.Bundle == nil {
+		return nil, fmt.Errorf("cannot roll back installation %q to revision %s, which does not reference a bundle", name, revision)
+	}
+	c.Parameters = restored.Parameters
+
+	var a action.Action
+	if isLowerVersion(restored.Bundle, c.Bundle) {
+		a = &action.Downgrade{Driver: m.Driver, Bundle: restored.Bundle, Options: m.actionOptions()}
+	} else {
+		c.Bundle = restored.Bundle
+		a = &action.Upgrade{Driver: m.Driver, Options: m.actionOptions()}
+	}
+	return c, m.run(c, a, true, revision, opCfgs)
+}
+
+// isLowerVersion reports whether the version of bundle a is lower than the
+// version of bundle b. Versions that are not valid semver are never lower.
+func isLowerVersion(a, b *bundle.Bundle) bool {
+	if b == nil {
+		return false
+	}
+	va, err := semver.NewVersion(a.Version)
+	if err != nil {
+		return false
+	}
+	vb, err := semver.NewVersion(b.Version)
+	if err != nil {
+		return false
+	}
+	return va.LessThan(vb)
+}
+
+// load reads an installation that exists and has not been uninstalled.
+func (m *Manager) load(name string) (*claim.Claim, error) {
+	c, err := m.Claims.Read(name)
+	if err != nil {
+		if err == claim.ErrClaimNotFound {
+			return nil, fmt.Errorf("installation %q: %w", name, claim.ErrClaimNotFound)
+		}
+		return nil, err
+	}
+	if isUninstalled(c) {
+		return nil, fmt.Errorf("installation %q was uninstalled: %w", name, claim.ErrClaimNotFound)
+	}
+	return &c, nil
+}
+
+// run runs the action against the claim, and persists the claim around it. The
+// new revision records restored as the revision it restored, if any.
+func (m *Manager) run(c *claim.Claim, a action.Action, persist bool, restored string, opCfgs []action.OperationConfigFunc) error {
+	if !persist {
+		return a.Run(c, m.Credentials, opCfgs...)
+	}
+
+	// Save the underway claim last, once every other configuration was applied
+	// and right before the driver runs.
+	started := false
+	underway := func(op *driver.Operation) error {
+		// Nothing of the previous result applies to the new operation.
+		c.Result = claim.Result{RestoredRevision: restored}
+		c.Labels, c.Annotations = op.Labels, op.Annotations
+		c.Update(op.Action, claim.StatusUnderway)
+		op.Revision = c.Revision
+		if err := m.Claims.Save(*c); err != nil {
+			return fmt.Errorf("failed to save underway claim for installation %q: %v", c.Name, err)
+		}
+		started = true
+		return nil
+	}
+	cfgs := append(append([]action.OperationConfigFunc{}, opCfgs...), underway)
+
+	runErr := a.Run(c, m.Credentials, cfgs...)
+	if !started {
+		// The driver never ran, so the stored claim is still accurate.
+		return runErr
+	}
+
+	if err := m.Claims.Save(*c); err != nil {
+		if runErr != nil {
+			return fmt.Errorf("%v; additionally, failed to save claim for installation %q: %v", runErr, c.Name, err)
+		}
+		return fmt.Errorf("failed to save claim for installation %q: %v", c.Name, err)
+	}
+	return runErr
+}
+
+// actionOptions returns the options of the actions created by the manager,
+// which do not lock the installation since the manager already holds the lock.
+func (m *Manager) actionOptions() action.Options {
+	opts := m.Options
+	opts.Locks = nil
+	return opts
+}
+
+// release calls unlock, and reports its error through err unless err is
+// already set.
+func release(unlock func() error, err *error) {
+	if unlockErr := unlock(); unlockErr != nil && *err == nil {
+		*err = unlockErr
+	}
+}
+
+func isUninstalled(c claim.Claim) bool {
+	return c.Result.Action == claim.ActionUninstall && c.Result.Status == claim.StatusSuccess
+}
+
+// modifies reports whether running the action may modify the installation.
+func modifies(a action.Action, b *bundle.Bundle) bool {
+	switch act := a.(type) {
+	case *action.Status:
+		return false
+	case *action.RunCustom:
+		if b == nil {
+			return false
+		}
+		def, ok := b.Actions[act.Action]
+		return ok && def.Modifies
+	default:
+		return true
+	}
+}
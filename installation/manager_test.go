@@ -0,0 +1,381 @@
+package installation
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cnabio/cnab-go/action"
+	"github.com/cnabio/cnab-go/bundle"
+	"github.com/cnabio/cnab-go/bundle/definition"
+	"github.com/cnabio/cnab-go/claim"
+	"github.com/cnabio/cnab-go/driver"
+	"github.com/cnabio/cnab-go/utils/crud"
+)
+
+// mockDriver records the stored claim at the time it runs.
+type mockDriver struct {
+	claims    claim.Store
+	Operation *driver.Operation
+	Stored    claim.Claim
+	Error     error
+}
+
+func (d *mockDriver) Handles(imageType string) bool {
+	return true
+}
+
+func (d *mockDriver) Run(op *driver.Operation) (driver.OperationResult, error) {
+	d.Operation = op
+	d.Stored, _ = d.claims.Read(op.Installation)
+	return driver.OperationResult{}, d.Error
+}
+
+func mockBundle(version string) *bundle.Bundle {
+	return &bundle.Bundle{
+		Name:    "mybun",
+		Version: version,
+		InvocationImages: []bundle.InvocationImage{
+			{BaseImage: bundle.BaseImage{Image: "mybun:" + version, ImageType: "docker"}},
+		},
+		Definitions: map[string]*definition.Schema{
+			"string": {Type: "string"},
+		},
+		Parameters: map[string]bundle.Parameter{
+			"color": {Definition: "string"},
+		},
+		Actions: map[string]bundle.Action{
+			"logs":    {},
+			"migrate": {Modifies: true},
+		},
+	}
+}
+
+func newTestManager(t *testing.T) (*Manager, *mockDriver, func()) {
+	dir, err := ioutil.TempDir("", "cnabgotest")
+	require.NoError(t, err)
+
+	store := claim.NewClaimStore(crud.NewFileSystemStore(dir, "json"))
+	d := &mockDriver{claims: store}
+	m := &Manager{Driver: d, Claims: store}
+	return m, d, func() { os.RemoveAll(dir) }
+}
+
+func discard(op *driver.Operation) error {
+	op.Out = ioutil.Discard
+	return nil
+}
+
+func TestManager_Install(t *testing.T) {
+	m, d, cleanup := newTestManager(t)
+	defer cleanup()
+
+	c, err := m.Install("test", mockBundle("0.1.0"), map[string]interface{}{"color": "blue"}, discard)
+	require.NoError(t, err)
+	assert.Equal(t, claim.StatusSuccess, c.Result.Status)
+
+	t.Run("underway claim is saved before the driver runs", func(t *testing.T) {
+		assert.Equal(t, claim.ActionInstall, d.Stored.Result.Action)
+		assert.Equal(t, claim.StatusUnderway, d.Stored.Result.Status)
+		assert.Equal(t, d.Stored.Revision, d.Operation.Revision)
+	})
+
+	t.Run("the invocation image gets the underway revision and claim", func(t *testing.T) {
+		assert.Equal(t, d.Operation.Revision, d.Operation.Environment["CNAB_REVISION"])
+
+		given, err := claim.Parse([]byte(d.Operation.Files["/cnab/claim.json"]))
+		require.NoError(t, err)
+		assert.Equal(t, d.Stored.Revision, given.Revision)
+		assert.Equal(t, claim.StatusUnderway, given.Result.Status)
+	})
+
+	t.Run("final result is saved", func(t *testing.T) {
+		stored, err := m.Claims.Read("test")
+		require.NoError(t, err)
+		assert.Equal(t, claim.ActionInstall, stored.Result.Action)
+		assert.Equal(t, claim.StatusSuccess, stored.Result.Status)
+		assert.Equal(t, c.Revision, stored.Revision)
+		assert.Equal(t, "blue", stored.Parameters["color"])
+	})
+
+	t.Run("refuse to install over an existing installation", func(t *testing.T) {
+		_, err := m.Install("test", mockBundle("0.1.0"), nil, discard)
+		assert.True(t, errors.Is(err, ErrInstallationExists))
+	})
+
+	t.Run("install again once uninstalled", func(t *testing.T) {
+		_, err := m.Uninstall("test", discard)
+		require.NoError(t, err)
+		_, err = m.Install("test", mockBundle("0.1.0"), nil, discard)
+		assert.NoError(t, err)
+	})
+}
+
+func TestManager_InstallFailure(t *testing.T) {
+	m, d, cleanup := newTestManager(t)
+	defer cleanup()
+
+	d.Error = errors.New("I always fail")
+	_, err := m.Install("test", mockBundle("0.1.0"), nil, discard)
+	require.EqualError(t, err, "I always fail")
+
+	stored, err := m.Claims.Read("test")
+	require.NoError(t, err)
+	assert.Equal(t, claim.StatusFailure, stored.Result.Status)
+	assert.Equal(t, "I always fail", stored.Result.Message)
+}
+
+func TestManager_NothingSavedWhenDriverNeverRuns(t *testing.T) {
+	m, _, cleanup := newTestManager(t)
+	defer cleanup()
+
+	_, err := m.Install("test", mockBundle("0.1.0"), map[string]interface{}{"unknown": true}, discard)
+	require.Error(t, err)
+
+	_, err = m.Claims.Read("test")
+	assert.Equal(t, claim.ErrClaimNotFound, err)
+}
+
+func TestManager_Upgrade(t *testing.T) {
+	m, d, cleanup := newTestManager(t)
+	defer cleanup()
+
+	t.Run("refuse to upgrade a missing installation", func(t *testing.T) {
+		_, err := m.Upgrade("test", mockBundle("0.2.0"), nil, discard)
+		assert.True(t, errors.Is(err, claim.ErrClaimNotFound))
+	})
+
+	_, err := m.Install("test", mockBundle("0.1.0"), map[string]interface{}{"color": "blue"}, discard)
+	require.NoError(t, err)
+
+	c, err := m.Upgrade("test", mockBundle("0.2.0"), nil, discard)
+	require.NoError(t, err)
+	assert.Equal(t, claim.StatusUnderway, d.Stored.Result.Status)
+	assert.Equal(t, claim.ActionUpgrade, d.Stored.Result.Action)
+
+	stored, err := m.Claims.Read("test")
+	require.NoError(t, err)
+	assert.Equal(t, c.Revision, stored.Revision)
+	assert.Equal(t, "0.2.0", stored.Bundle.Version)
+	assert.Equal(t, "blue", stored.Parameters["color"], "parameters should be kept when none are given")
+
+	t.Run("downgrade", func(t *testing.T) {
+		_, err := m.Downgrade("test", mockBundle("0.1.0"), nil, discard)
+		require.NoError(t, err)
+
+		stored, err := m.Claims.Read("test")
+		require.NoError(t, err)
+		assert.Equal(t, claim.ActionDowngrade, stored.Result.Action)
+		assert.Equal(t, "0.1.0", stored.Bundle.Version)
+	})
+
+	t.Run("refuse to upgrade an uninstalled installation", func(t *testing.T) {
+		_, err := m.Uninstall("test", discard)
+		require.NoError(t, err)
+		_, err = m.Upgrade("test", mockBundle("0.2.0"), nil, discard)
+		assert.True(t, errors.Is(err, claim.ErrClaimNotFound))
+	})
+}
+
+func TestManager_Rollback(t *testing.T) {
+	m, d, cleanup := newTestManager(t)
+	defer cleanup()
+
+	installed, err := m.Install("test", mockBundle("0.1.0"), map[string]interface{}{"color": "blue"}, discard)
+	require.NoError(t, err)
+	upgraded, err := m.Upgrade("test", mockBundle("0.2.0"), map[string]interface{}{"color": "red"}, discard)
+	require.NoError(t, err)
+
+	t.Run("downgrade to a lower version", func(t *testing.T) {
+		c, err := m.Rollback("test", installed.Revision, discard)
+		require.NoError(t, err)
+		assert.Equal(t, claim.ActionDowngrade, c.Result.Action)
+		assert.Equal(t, claim.StatusSuccess, c.Result.Status)
+		assert.Equal(t, installed.Revision, c.Result.RestoredRevision)
+		assert.Equal(t, "mybun:0.1.0", d.Operation.Image.Image)
+		assert.Equal(t, "blue", d.Operation.Parameters["color"])
+
+		stored, err := m.Claims.Read("test")
+		require.NoError(t, err)
+		assert.Equal(t, c.Revision, stored.Revision)
+		assert.Equal(t, "0.1.0", stored.Bundle.Version)
+		assert.Equal(t, "blue", stored.Parameters["color"])
+		assert.Equal(t, installed.Revision, stored.Result.RestoredRevision)
+	})
+
+	t.Run("upgrade to a higher version", func(t *testing.T) {
+		c, err := m.Rollback("test", upgraded.Revision, discard)
+		require.NoError(t, err)
+		assert.Equal(t, claim.ActionUpgrade, c.Result.Action)
+		assert.Equal(t, upgraded.Revision, c.Result.RestoredRevision)
+		assert.Equal(t, "0.2.0", c.Bundle.Version)
+		assert.Equal(t, "red", c.Parameters["color"])
+	})
+
+	t.Run("the link is not carried over to later revisions", func(t *testing.T) {
+		c, err := m.Upgrade("test", mockBundle("0.3.0"), nil, discard)
+		require.NoError(t, err)
+		assert.Empty(t, c.Result.RestoredRevision)
+	})
+
+	t.Run("refuse to roll back to an unknown revision", func(t *testing.T) {
+		_, err := m.Rollback("test", "missing", discard)
+		assert.True(t, errors.Is(err, claim.ErrClaimNotFound))
+	})
+
+	t.Run("refuse to roll back to the current revision", func(t *testing.T) {
+		current, err := m.Claims.Read("test")
+		require.NoError(t, err)
+		_, err = m.Rollback("test", current.Revision, discard)
+		assert.EqualError(t, err, `cannot roll back installation "test" to revision `+current.Revision+`, which is its current revision`)
+	})
+}
+
+func TestManager_Run(t *testing.T) {
+	m, d, cleanup := newTestManager(t)
+	defer cleanup()
+
+	installed, err := m.Install("test", mockBundle("0.1.0"), nil, discard)
+	require.NoError(t, err)
+
+	t.Run("read-only actions are not persisted", func(t *testing.T) {
+		_, err := m.Run("test", &action.Status{Driver: d}, discard)
+		require.NoError(t, err)
+		_, err = m.Run("test", &action.RunCustom{Driver: d, Action: "logs"}, discard)
+		require.NoError(t, err)
+
+		stored, err := m.Claims.Read("test")
+		require.NoError(t, err)
+		assert.Equal(t, installed.Revision, stored.Revision)
+	})
+
+	t.Run("modifying custom actions are persisted", func(t *testing.T) {
+		_, err := m.Run("test", &action.RunCustom{Driver: d, Action: "migrate"}, discard)
+		require.NoError(t, err)
+		assert.Equal(t, claim.StatusUnderway, d.Stored.Result.Status)
+
+		stored, err := m.Claims.Read("test")
+		require.NoError(t, err)
+		assert.Equal(t, "migrate", stored.Result.Action)
+		assert.Equal(t, claim.StatusSuccess, stored.Result.Status)
+	})
+}
+
+func TestManager_Logs(t *testing.T) {
+	m, _, cleanup := newTestManager(t)
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "cnabgotest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	logs := claim.NewLogStore(crud.NewFileSystemStore(dir, "log"))
+	logs.ChunkSize = 4
+	m.Options.Logs = &logs
+
+	var underway claim.Claim
+	var streamed string
+	m.Driver = driverFunc(func(op *driver.Operation) (driver.OperationResult, error) {
+		fmt.Fprint(op.Out, "installing\n")
+		underway, _ = m.Claims.Read(op.Installation)
+		// The log of the running operation can be read by its revision.
+		r, err := logs.Open(op.Installation, underway.Revision)
+		if err != nil {
+			return driver.OperationResult{}, err
+		}
+		defer r.Close()
+		data, err := ioutil.ReadAll(r)
+		streamed = string(data)
+		return driver.OperationResult{}, err
+	})
+
+	c, err := m.Install("test", mockBundle("0.1.0"), nil, discard)
+	require.NoError(t, err)
+	assert.Equal(t, "installing\n", streamed, "the log should be readable while the operation runs")
+	assert.Equal(t, underway.Revision, c.Result.LogRevision, "the final claim should link to the log of the underway revision")
+
+	r, err := logs.Open("test", c.Result.LogRevision)
+	require.NoError(t, err)
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "installing\n", string(data))
+}
+
+// failingListStore is a store whose List always fails.
+type failingListStore struct {
+	crud.Store
+}
+
+func (s failingListStore) List(itemType string) ([]string, error) {
+	return nil, errors.New("log store down")
+}
+
+func TestManager_LogsUnavailable(t *testing.T) {
+	m, d, cleanup := newTestManager(t)
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "cnabgotest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	logs := claim.NewLogStore(failingListStore{crud.NewFileSystemStore(dir, "log")})
+	m.Options.Logs = &logs
+
+	_, err = m.Install("test", mockBundle("0.1.0"), nil, discard)
+	require.EqualError(t, err, "log store down")
+	assert.Nil(t, d.Operation, "the driver should not run")
+
+	c, err := m.Claims.Read("test")
+	require.NoError(t, err)
+	assert.Equal(t, claim.ActionInstall, c.Result.Action)
+	assert.Equal(t, claim.StatusFailure, c.Result.Status, "the claim should not be left underway")
+	assert.Equal(t, "log store down", c.Result.Message)
+}
+
+// driverFunc adapts a function to the driver.Driver interface.
+type driverFunc func(op *driver.Operation) (driver.OperationResult, error)
+
+func (f driverFunc) Handles(imageType string) bool {
+	return true
+}
+
+func (f driverFunc) Run(op *driver.Operation) (driver.OperationResult, error) {
+	return f(op)
+}
+
+func TestManager_Lock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cnabgotest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	backing := crud.NewFileSystemStore(dir, "json")
+	locks := backing.(crud.Locker)
+	store := claim.NewClaimStore(backing)
+	d := &mockDriver{claims: store}
+	m := &Manager{Driver: d, Claims: store, Options: action.Options{Locks: locks}}
+
+	_, err = m.Install("test", mockBundle("0.1.0"), nil, discard)
+	require.NoError(t, err, "the manager should not conflict with the lock it holds")
+
+	lease, err := locks.Lock("test", "someone else", time.Minute)
+	require.NoError(t, err)
+
+	d.Operation = nil
+	_, err = m.Upgrade("test", mockBundle("0.2.0"), nil, discard)
+	var locked *crud.LockedError
+	require.True(t, errors.As(err, &locked), "expected a LockedError, got %v", err)
+	assert.Nil(t, d.Operation, "the driver should not run")
+
+	_, err = m.Run("test", &action.Status{Driver: d}, discard)
+	require.NoError(t, err, "read-only actions should not take the lock")
+	assert.NotNil(t, d.Operation)
+
+	require.NoError(t, locks.Unlock(lease))
+	_, err = m.Upgrade("test", mockBundle("0.2.0"), nil, discard)
+	assert.NoError(t, err)
+}
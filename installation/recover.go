@@ -0,0 +1,120 @@
+package installation
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cnabio/cnab-go/claim"
+	"github.com/cnabio/cnab-go/driver"
+)
+
+// ErrInspectNotSupported is returned when recovering installations with a
+// driver that does not implement driver.Inspector.
+var ErrInspectNotSupported = errors.New("the driver cannot inspect operations")
+
+// Recovery is the outcome of recovering one underway installation.
+type Recovery struct {
+	Installation string
+	// Revision is the underway revision of the installation.
+	Revision string
+	// State is the state of the operation, as reported by the driver.
+	State driver.OperationState
+	// Claim is the claim saved with the final status of the operation. It is
+	// nil when the claim was left underway, because the operation is still
+	// running or could not be inspected.
+	Claim *claim.Claim
+	// Err is the error that prevented the recovery of the installation.
+	Err error
+}
+
+// Recover completes the claims that have been underway for longer than
+// olderThan, which happens when the process running their action stopped
+// before it saved the result.
+//
+// The driver is asked whether the operation of each claim is still running:
+// operations that are still running are left alone, and the claims of the
+// others are saved with a success or failure status. Operations the driver has
+// no trace of are presumed to have failed. The outputs of operations that
+// succeeded are not collected.
+//
+// Installations that are locked are skipped, since their action is still in
+// progress. Pick olderThan longer than the actions are expected to run.
+func (m *Manager) Recover(olderThan time.Duration) ([]Recovery, error) {
+	inspector, ok := m.Driver.(driver.Inspector)
+	if !ok {
+		return nil, ErrInspectNotSupported
+	}
+
+	stuck, err := m.Claims.Query(claim.Query{
+		Status:         claim.StatusUnderway,
+		ModifiedBefore: time.Now().Add(-olderThan),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	recoveries := make([]Recovery, 0, len(stuck))
+	for _, c := range stuck {
+		rec := Recovery{Installation: c.Name, Revision: c.Revision}
+		rec.Claim, rec.State, rec.Err = m.recover(inspector, c.Name, c.Revision)
+		recoveries = append(recoveries, rec)
+	}
+	return recoveries, nil
+}
+
+// recover completes the underway revision of an installation from the state of
+// its operation.
+func (m *Manager) recover(inspector driver.Inspector, name, revision string) (_ *claim.Claim, _ driver.OperationState, err error) {
+	unlock, err := m.Options.Lock(name)
+	if err != nil {
+		return nil, "", err
+	}
+	defer release(unlock, &err)
+
+	c, err := m.Claims.Read(name)
+	if err != nil {
+		return nil, "", err
+	}
+	if c.Revision != revision || c.Result.Status != claim.StatusUnderway {
+		// The action completed meanwhile.
+		return nil, "", nil
+	}
+
+	status, err := inspector.Inspect(name, revision)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to inspect the operation of installation %q: %v", name, err)
+	}
+
+	switch status.State {
+	case driver.OperationRunning:
+		return nil, status.State, nil
+	case driver.OperationSucceeded:
+		c.Result.Message = "recovered: the operation completed, but its outputs were not collected"
+		c.Update(c.Result.Action, claim.StatusSuccess)
+	case driver.OperationFailed:
+		c.Result.Message = "recovered: the operation failed"
+		if status.Message != "" {
+			c.Result.Message += ": " + status.Message
+		}
+		c.Update(c.Result.Action, claim.StatusFailure)
+	case driver.OperationNotFound:
+		c.Result.Message = "recovered: the operation was not found, and is presumed to have failed"
+		c.Update(c.Result.Action, claim.StatusFailure)
+	default:
+		return nil, status.State, fmt.Errorf("unknown state %q of the operation of installation %q", status.State, name)
+	}
+	if status.OperationID != "" {
+		c.Result.OperationID = status.OperationID
+	}
+	c.Result.ExitCode = status.ExitCode
+	if m.Options.Logs != nil {
+		// The log of the operation is stored under its underway revision.
+		c.Result.LogRevision = revision
+	}
+
+	if err := m.Claims.Save(c); err != nil {
+		return nil, status.State, fmt.Errorf("failed to save recovered claim for installation %q: %v", name, err)
+	}
+	return &c, status.State, nil
+}
@@ -0,0 +1,110 @@
+package installation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cnabio/cnab-go/claim"
+	"github.com/cnabio/cnab-go/driver"
+)
+
+// inspectingDriver reports the state of operations by installation.
+type inspectingDriver struct {
+	mockDriver
+	States map[string]driver.OperationStatus
+}
+
+func (d *inspectingDriver) Inspect(installation, revision string) (driver.OperationStatus, error) {
+	status, ok := d.States[installation]
+	if !ok {
+		return driver.OperationStatus{}, errors.New("unreachable")
+	}
+	return status, nil
+}
+
+func TestManager_Recover(t *testing.T) {
+	m, _, cleanup := newTestManager(t)
+	defer cleanup()
+
+	exitCode := 3
+	d := &inspectingDriver{States: map[string]driver.OperationStatus{
+		"running":   {State: driver.OperationRunning, OperationID: "job-1"},
+		"completed": {State: driver.OperationSucceeded, OperationID: "job-2"},
+		"failed":    {State: driver.OperationFailed, OperationID: "job-3", ExitCode: &exitCode, Message: "BackoffLimitExceeded"},
+		"lost":      {State: driver.OperationNotFound},
+		"recent":    {State: driver.OperationSucceeded},
+	}}
+	m.Driver = d
+
+	underway := func(name string, since time.Duration) claim.Claim {
+		c, err := claim.New(name)
+		require.NoError(t, err)
+		c.Bundle = mockBundle("0.1.0")
+		c.Update(claim.ActionInstall, claim.StatusUnderway)
+		c.Modified = time.Now().Add(-since)
+		require.NoError(t, m.Claims.Save(*c))
+		return *c
+	}
+	running := underway("running", time.Hour)
+	underway("completed", time.Hour)
+	underway("failed", time.Hour)
+	underway("lost", time.Hour)
+	underway("recent", time.Second)
+	unreachable := underway("unreachable", time.Hour)
+
+	recoveries, err := m.Recover(time.Minute)
+	require.NoError(t, err)
+	require.Len(t, recoveries, 5, "recent underway claims should not be recovered")
+
+	byName := map[string]Recovery{}
+	for _, rec := range recoveries {
+		byName[rec.Installation] = rec
+	}
+
+	assert.Equal(t, driver.OperationRunning, byName["running"].State)
+	assert.Nil(t, byName["running"].Claim)
+	stored, err := m.Claims.Read("running")
+	require.NoError(t, err)
+	assert.Equal(t, running.Revision, stored.Revision, "running operations should be left underway")
+
+	completed := byName["completed"]
+	require.NotNil(t, completed.Claim)
+	assert.Equal(t, claim.ActionInstall, completed.Claim.Result.Action)
+	assert.Equal(t, claim.StatusSuccess, completed.Claim.Result.Status)
+	assert.Equal(t, "job-2", completed.Claim.Result.OperationID)
+	assert.NotEqual(t, completed.Revision, completed.Claim.Revision)
+
+	failed := byName["failed"]
+	require.NotNil(t, failed.Claim)
+	assert.Equal(t, claim.StatusFailure, failed.Claim.Result.Status)
+	assert.Contains(t, failed.Claim.Result.Message, "BackoffLimitExceeded")
+	assert.Equal(t, &exitCode, failed.Claim.Result.ExitCode)
+
+	lost := byName["lost"]
+	require.NotNil(t, lost.Claim)
+	assert.Equal(t, claim.StatusFailure, lost.Claim.Result.Status)
+	stored, err = m.Claims.Read("lost")
+	require.NoError(t, err)
+	assert.Equal(t, lost.Claim.Revision, stored.Revision)
+
+	assert.Error(t, byName["unreachable"].Err)
+	stored, err = m.Claims.Read("unreachable")
+	require.NoError(t, err)
+	assert.Equal(t, unreachable.Revision, stored.Revision)
+
+	recoveries, err = m.Recover(time.Minute)
+	require.NoError(t, err)
+	assert.Len(t, recoveries, 2, "only the running and unreachable operations should still be underway")
+}
+
+func TestManager_RecoverNotSupported(t *testing.T) {
+	m, _, cleanup := newTestManager(t)
+	defer cleanup()
+
+	_, err := m.Recover(time.Minute)
+	assert.Equal(t, ErrInspectNotSupported, err)
+}
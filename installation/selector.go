@@ -0,0 +1,86 @@
+package installation
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/cnabio/cnab-go/claim"
+)
+
+// Selector reports whether a claim should be selected.
+type Selector func(c claim.Claim) bool
+
+// Select returns the claims of the store matched by every selector, sorted by
+// installation name.
+func Select(store claim.Store, selectors ...Selector) ([]claim.Claim, error) {
+	claims, err := store.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []claim.Claim
+	for _, c := range claims {
+		if matchesAll(c, selectors) {
+			selected = append(selected, c)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].Name < selected[j].Name
+	})
+	return selected, nil
+}
+
+func matchesAll(c claim.Claim, selectors []Selector) bool {
+	for _, sel := range selectors {
+		if !sel(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Installed selects the installations that were not uninstalled.
+func Installed() Selector {
+	return func(c claim.Claim) bool {
+		return !isUninstalled(c)
+	}
+}
+
+// ForBundle selects the installations of the bundle with the given name.
+func ForBundle(name string) Selector {
+	return func(c claim.Claim) bool {
+		return c.Bundle != nil && c.Bundle.Name == name
+	}
+}
+
+// WithLabels selects the installations that have all the labels, with the
+// same values.
+func WithLabels(labels map[string]string) Selector {
+	return func(c claim.Claim) bool {
+		for k, v := range labels {
+			if actual, ok := c.Labels[k]; !ok || actual != v {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// BundleVersion selects the installations whose bundle version satisfies the
+// semver constraint, for example "< 2.0.0". Bundles without a valid semver
+// version are not selected.
+func BundleVersion(constraint string) (Selector, error) {
+	constraints, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version constraint %q: %v", constraint, err)
+	}
+	return func(c claim.Claim) bool {
+		if c.Bundle == nil {
+			return false
+		}
+		v, err := semver.NewVersion(c.Bundle.Version)
+		return err == nil && constraints.Check(v)
+	}, nil
+}
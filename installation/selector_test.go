@@ -0,0 +1,59 @@
+package installation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cnabio/cnab-go/action"
+	"github.com/cnabio/cnab-go/claim"
+)
+
+func TestSelect(t *testing.T) {
+	m, _, cleanup := newTestManager(t)
+	defer cleanup()
+
+	for name, version := range map[string]string{"c": "0.1.0", "a": "0.2.0", "b": "1.0.0"} {
+		labels := action.WithLabels(map[string]string{"team": "platform", "env": name})
+		_, err := m.Install(name, mockBundle(version), nil, discard, labels)
+		require.NoError(t, err)
+	}
+	other := mockBundle("0.1.0")
+	other.Name = "otherbun"
+	_, err := m.Install("d", other, nil, discard)
+	require.NoError(t, err)
+	_, err = m.Install("e", mockBundle("0.1.0"), nil, discard)
+	require.NoError(t, err)
+	_, err = m.Uninstall("e", discard)
+	require.NoError(t, err)
+
+	names := func(claims []claim.Claim) []string {
+		var names []string
+		for _, c := range claims {
+			names = append(names, c.Name)
+		}
+		return names
+	}
+
+	all, err := Select(m.Claims)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, names(all))
+
+	belowOne, err := BundleVersion("< 1.0.0")
+	require.NoError(t, err)
+	selected, err := Select(m.Claims, Installed(), ForBundle("mybun"), belowOne)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "c"}, names(selected))
+
+	_, err = BundleVersion("not a constraint")
+	assert.Error(t, err)
+
+	selected, err = Select(m.Claims, WithLabels(map[string]string{"team": "platform", "env": "b"}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, names(selected))
+
+	queried, err := m.Claims.Query(claim.Query{Labels: map[string]string{"team": "platform"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, names(queried))
+}
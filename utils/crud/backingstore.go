@@ -1,6 +1,10 @@
 package crud
 
+import "time"
+
 var _ Store = &BackingStore{}
+var _ Locker = &BackingStore{}
+var _ Indexer = &BackingStore{}
 
 // BackingStore wraps another store that may have Connect/Close methods that
 // need to be called.
@@ -113,3 +117,83 @@ func (s *BackingStore) Delete(itemType string, name string) error {
 
 	return s.backingStore.Delete(itemType, name)
 }
+
+// Lock acquires a lock from the underlying store, which must implement Locker.
+func (s *BackingStore) Lock(name, owner string, ttl time.Duration) (Lease, error) {
+	locker, ok := s.backingStore.(Locker)
+	if !ok {
+		return Lease{}, ErrLockingNotSupported
+	}
+
+	err := s.Connect()
+	if err != nil {
+		return Lease{}, err
+	}
+
+	defer s.autoClose()
+
+	return locker.Lock(name, owner, ttl)
+}
+
+// Unlock releases a lock acquired from the underlying store.
+func (s *BackingStore) Unlock(lease Lease) error {
+	locker, ok := s.backingStore.(Locker)
+	if !ok {
+		return ErrLockingNotSupported
+	}
+
+	err := s.Connect()
+	if err != nil {
+		return err
+	}
+
+	defer s.autoClose()
+
+	return locker.Unlock(lease)
+}
+
+// indexer returns the Indexer of the underlying store, or one that indexes its
+// records as records of IndexItemType.
+func (s *BackingStore) indexer() Indexer {
+	if indexer, ok := s.backingStore.(Indexer); ok {
+		return indexer
+	}
+	return storeIndexer{store: s.backingStore}
+}
+
+// SaveIndex saves the index of a record, so that it can be queried.
+func (s *BackingStore) SaveIndex(itemType, name string, index Index) error {
+	err := s.Connect()
+	if err != nil {
+		return err
+	}
+
+	defer s.autoClose()
+
+	return s.indexer().SaveIndex(itemType, name, index)
+}
+
+// DeleteIndex deletes the index of a record, if any.
+func (s *BackingStore) DeleteIndex(itemType, name string) error {
+	err := s.Connect()
+	if err != nil {
+		return err
+	}
+
+	defer s.autoClose()
+
+	return s.indexer().DeleteIndex(itemType, name)
+}
+
+// Query returns the records of itemType whose index matches the query. It is
+// evaluated by the underlying store when it implements Indexer.
+func (s *BackingStore) Query(itemType string, q Query) ([]Match, error) {
+	err := s.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.autoClose()
+
+	return s.indexer().Query(itemType, q)
+}
@@ -1,7 +1,10 @@
 package crud
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -209,3 +212,23 @@ func TestBackingStore_ReadAll(t *testing.T) {
 		})
 	}
 }
+
+func TestBackingStore_Lock(t *testing.T) {
+	t.Run("unsupported", func(t *testing.T) {
+		bs := NewBackingStore(NewMockStore())
+		_, err := bs.Lock("foo", "alice", time.Minute)
+		assert.Equal(t, ErrLockingNotSupported, err)
+		assert.Equal(t, ErrLockingNotSupported, bs.Unlock(Lease{Name: "foo"}))
+	})
+
+	t.Run("supported", func(t *testing.T) {
+		tmdir, err := ioutil.TempDir("", "duffle-test-")
+		require.NoError(t, err)
+		defer os.RemoveAll(tmdir)
+
+		bs := NewBackingStore(NewFileSystemStore(tmdir, "data"))
+		lease, err := bs.Lock("foo", "alice", time.Minute)
+		require.NoError(t, err)
+		assert.NoError(t, bs.Unlock(lease))
+	})
+}
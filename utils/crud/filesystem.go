@@ -1,19 +1,22 @@
 package crud
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // ErrRecordDoesNotExist represents when file path is not found on file system
 var ErrRecordDoesNotExist = errors.New("File does not exist")
 
 // NewFileSystemStore creates a Store backed by a file system directory.
-// Each key is represented by a file in that directory.
+// Each key is represented by a file in that directory. The store implements
+// Locker, with one lock file per lock.
 func NewFileSystemStore(baseDirectory string, fileExtension string) Store {
 	return fileSystemStore{
 		baseDirectory: baseDirectory,
@@ -69,6 +72,147 @@ func (s fileSystemStore) Delete(itemType string, name string) error {
 	return os.Remove(filename)
 }
 
+// lockDirectory is the directory, under the base directory, holding the lock files.
+const lockDirectory = "locks"
+
+// Lock acquires the lock by creating a lock file holding the lease. The file
+// is written aside and hard linked into place, so that the lease is never
+// observed partially written and only one owner can create it.
+func (s fileSystemStore) Lock(name, owner string, ttl time.Duration) (Lease, error) {
+	if err := s.ensure(lockDirectory); err != nil {
+		return Lease{}, err
+	}
+	lease, err := newLease(name, owner, ttl)
+	if err != nil {
+		return Lease{}, err
+	}
+	filename := s.lockFileOf(name)
+
+	// Retry when the lock changes hands while we look at it.
+	for i := 0; i < 3; i++ {
+		err := s.createLockFile(filename, lease)
+		if err == nil {
+			return lease, nil
+		}
+		if !os.IsExist(err) {
+			return Lease{}, err
+		}
+
+		held, err := readLockFile(filename)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return Lease{}, err
+		}
+		if !held.Expired(time.Now()) {
+			return Lease{}, &LockedError{Lease: held}
+		}
+
+		broken, err := s.takeLockFile(filename, held.ID, lease.ID)
+		if err != nil {
+			return Lease{}, err
+		}
+		if broken {
+			lease.Stale = &held
+		}
+	}
+	return Lease{}, fmt.Errorf("failed to acquire the lock on %q: the lock kept changing hands", name)
+}
+
+// Unlock releases the lock by removing the lock file, if it still holds the lease.
+func (s fileSystemStore) Unlock(lease Lease) error {
+	filename := s.lockFileOf(lease.Name)
+	released, err := s.takeLockFile(filename, lease.ID, lease.ID)
+	if err != nil {
+		return err
+	}
+	if released {
+		return nil
+	}
+
+	current, err := readLockFile(filename)
+	if os.IsNotExist(err) {
+		return lockLost(lease, nil)
+	}
+	if err != nil {
+		return err
+	}
+	return lockLost(lease, &current)
+}
+
+func (s fileSystemStore) lockFileOf(name string) string {
+	return filepath.Join(s.baseDirectory, lockDirectory, name+".lock")
+}
+
+func (s fileSystemStore) createLockFile(filename string, lease Lease) error {
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+	tmp := filename + "." + lease.ID + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	return os.Link(tmp, filename)
+}
+
+// takeLockFile removes the lock file if it holds the lease with the given ID.
+// The lease is checked before the file is touched, so that the lock file of
+// another owner is never taken away. The file is then moved aside and checked
+// again, in case it changed hands in between: it is put back when it did, and
+// taking it fails if yet another lock file was created meanwhile.
+func (s fileSystemStore) takeLockFile(filename, leaseID, takerID string) (bool, error) {
+	held, err := readLockFile(filename)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if held.ID != leaseID {
+		return false, nil
+	}
+
+	aside := filename + "." + takerID + ".taken"
+	if err := os.Rename(filename, aside); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer os.Remove(aside)
+	testHookLockFileTaken()
+
+	taken, err := readLockFile(aside)
+	if err == nil && taken.ID == leaseID {
+		return true, nil
+	}
+	if linkErr := os.Link(aside, filename); linkErr != nil {
+		if os.IsExist(linkErr) && err == nil {
+			return false, fmt.Errorf("lock file %s changed hands while it was being taken, and the lease of %s was lost", filename, taken.Owner)
+		}
+		return false, linkErr
+	}
+	return false, nil
+}
+
+// testHookLockFileTaken is called by takeLockFile once the lock file was moved aside.
+var testHookLockFileTaken = func() {}
+
+func readLockFile(filename string) (Lease, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return Lease{}, err
+	}
+	var lease Lease
+	if err := json.Unmarshal(data, &lease); err != nil {
+		return Lease{}, fmt.Errorf("invalid lock file %s: %v", filename, err)
+	}
+	return lease, nil
+}
+
 func (s fileSystemStore) fileNameOf(itemType string, name string) string {
 	return filepath.Join(s.baseDirectory, itemType, fmt.Sprintf("%s.%s", name, s.fileExtension))
 }
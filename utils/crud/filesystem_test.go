@@ -1,14 +1,20 @@
 package crud
 
 import (
+	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var _ Store = &fileSystemStore{}
+var _ Locker = &fileSystemStore{}
 
 func TestFilesystemStore(t *testing.T) {
 	const claims = "claims"
@@ -32,3 +38,136 @@ func TestFilesystemStore(t *testing.T) {
 	is.NoError(err)
 	is.Len(list, 0)
 }
+
+func TestFilesystemStore_Lock(t *testing.T) {
+	tmdir, err := ioutil.TempDir("", "duffle-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmdir)
+	s := NewFileSystemStore(tmdir, "data").(Locker)
+
+	lease, err := s.Lock("foo", "alice", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", lease.Name)
+	assert.Equal(t, "alice", lease.Owner)
+	assert.Nil(t, lease.Stale)
+
+	t.Run("held lock", func(t *testing.T) {
+		_, err := s.Lock("foo", "bob", time.Minute)
+		var locked *LockedError
+		require.True(t, errors.As(err, &locked), "expected a LockedError, got %v", err)
+		assert.Equal(t, lease.ID, locked.Lease.ID)
+		assert.Contains(t, err.Error(), `"foo" is locked by alice since`)
+
+		_, err = s.Lock("foo", "alice", time.Minute)
+		assert.True(t, errors.As(err, &locked), "locks should not be reentrant")
+	})
+
+	t.Run("other locks are independent", func(t *testing.T) {
+		other, err := s.Lock("bar", "bob", time.Minute)
+		require.NoError(t, err)
+		require.NoError(t, s.Unlock(other))
+	})
+
+	t.Run("release", func(t *testing.T) {
+		require.NoError(t, s.Unlock(lease))
+		relocked, err := s.Lock("foo", "bob", time.Minute)
+		require.NoError(t, err)
+		require.NoError(t, s.Unlock(relocked))
+	})
+}
+
+func TestFilesystemStore_StaleLock(t *testing.T) {
+	tmdir, err := ioutil.TempDir("", "duffle-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmdir)
+	s := NewFileSystemStore(tmdir, "data").(Locker)
+
+	stale, err := s.Lock("foo", "alice", time.Minute)
+	require.NoError(t, err)
+
+	// Expire the lease, as if alice crashed long ago.
+	expired := stale
+	expired.Expires = time.Now().Add(-time.Second)
+	data, err := json.Marshal(expired)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmdir, "locks", "foo.lock"), data, 0644))
+
+	lease, err := s.Lock("foo", "bob", time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, lease.Stale, "the broken lease should be reported")
+	assert.Equal(t, "alice", lease.Stale.Owner)
+
+	err = s.Unlock(stale)
+	assert.True(t, errors.Is(err, ErrLockLost))
+	assert.Contains(t, err.Error(), "was broken by bob")
+
+	require.NoError(t, s.Unlock(lease), "releasing a lost lease should not release the current one")
+
+	files, err := ioutil.ReadDir(filepath.Join(tmdir, "locks"))
+	require.NoError(t, err)
+	assert.Empty(t, files, "no lock file should be left behind")
+}
+
+func TestFilesystemStore_LockRaces(t *testing.T) {
+	tmdir, err := ioutil.TempDir("", "duffle-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmdir)
+	s := NewFileSystemStore(tmdir, "data").(Locker)
+	defer func() { testHookLockFileTaken = func() {} }()
+
+	expire := func(lease Lease) {
+		lease.Expires = time.Now().Add(-time.Second)
+		data, err := json.Marshal(lease)
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(tmdir, "locks", lease.Name+".lock"), data, 0644))
+	}
+	// compete runs a Lock the first time a lock file is moved aside.
+	compete := func(owner string) (*Lease, *error) {
+		var lease Lease
+		var err error
+		testHookLockFileTaken = func() {
+			testHookLockFileTaken = func() {}
+			lease, err = s.Lock("foo", owner, time.Minute)
+		}
+		return &lease, &err
+	}
+
+	t.Run("unlocking a broken lease leaves the lock file alone", func(t *testing.T) {
+		stale, err := s.Lock("foo", "alice", time.Minute)
+		require.NoError(t, err)
+		expire(stale)
+		bob, err := s.Lock("foo", "bob", time.Minute)
+		require.NoError(t, err)
+
+		carol, carolErr := compete("carol")
+		err = s.Unlock(stale)
+		assert.True(t, errors.Is(err, ErrLockLost))
+		assert.Empty(t, carol.ID, "the lock file of bob should not have been moved")
+		testHookLockFileTaken = func() {}
+
+		_, err = s.Lock("foo", "carol", time.Minute)
+		var locked *LockedError
+		require.True(t, errors.As(err, &locked), "expected a LockedError, got %v", err)
+		assert.Equal(t, "bob", locked.Lease.Owner)
+		assert.NoError(t, *carolErr)
+		require.NoError(t, s.Unlock(bob))
+	})
+
+	t.Run("only one owner breaks a stale lease", func(t *testing.T) {
+		stale, err := s.Lock("foo", "alice", time.Minute)
+		require.NoError(t, err)
+		expire(stale)
+
+		carol, carolErr := compete("carol")
+		_, err = s.Lock("foo", "bob", time.Minute)
+		require.NoError(t, *carolErr)
+		var locked *LockedError
+		require.True(t, errors.As(err, &locked), "expected a LockedError, got %v", err)
+		assert.Equal(t, "carol", locked.Lease.Owner)
+
+		require.NoError(t, s.Unlock(*carol))
+		files, err := ioutil.ReadDir(filepath.Join(tmdir, "locks"))
+		require.NoError(t, err)
+		assert.Empty(t, files, "no lock file should be left behind")
+	})
+}
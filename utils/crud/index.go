@@ -0,0 +1,241 @@
+package crud
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Index is the set of fields of a record that queries are evaluated against.
+//
+// Values are strings, int64 or []string. A condition on a []string field
+// compares each of its elements, and matches when any element does.
+type Index map[string]interface{}
+
+// Operator compares an indexed field to the value of a condition.
+type Operator string
+
+const (
+	// OpEqual matches fields equal to the value.
+	OpEqual Operator = "eq"
+	// OpGreaterOrEqual matches fields greater than or equal to the value.
+	OpGreaterOrEqual Operator = "gte"
+	// OpLess matches fields less than the value.
+	OpLess Operator = "lt"
+)
+
+// Condition restricts a query to the records whose field compares to the
+// value. Records without the field never match.
+type Condition struct {
+	Field    string
+	Operator Operator
+	Value    interface{}
+}
+
+// Query selects records on their index.
+type Query struct {
+	// Conditions are all met by the selected records.
+	Conditions []Condition
+	// SortBy is the indexed field the records are sorted by, with their name
+	// breaking ties. Records are sorted by name when it is not set.
+	SortBy     string
+	Descending bool
+	// Skip is the number of records skipped, after sorting.
+	Skip int
+	// Limit is the maximum number of records returned. All records are
+	// returned when it is not set.
+	Limit int
+}
+
+// Match is a record selected by a query, with its index.
+type Match struct {
+	Name  string
+	Index Index
+}
+
+// Indexer is implemented by stores that evaluate queries natively.
+//
+// Stores that do not implement Indexer are queried by the BackingStore, which
+// saves each index as a small record under IndexItemType and evaluates queries
+// against them, without reading the records themselves.
+type Indexer interface {
+	// SaveIndex saves the index of a record, replacing any previous index.
+	SaveIndex(itemType, name string, index Index) error
+	// DeleteIndex deletes the index of a record, if any.
+	DeleteIndex(itemType, name string) error
+	// Query returns the records of itemType whose index matches the query.
+	Query(itemType string, q Query) ([]Match, error)
+}
+
+// IndexItemType is the item type under which the indexes of the records of
+// itemType are stored.
+func IndexItemType(itemType string) string {
+	return itemType + "-index"
+}
+
+// storeIndexer indexes records of any store, as records of IndexItemType.
+type storeIndexer struct {
+	store Store
+}
+
+func (s storeIndexer) SaveIndex(itemType, name string, index Index) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return s.store.Save(IndexItemType(itemType), name, data)
+}
+
+func (s storeIndexer) DeleteIndex(itemType, name string) error {
+	if _, err := s.store.Read(IndexItemType(itemType), name); err != nil {
+		if err == ErrRecordDoesNotExist {
+			return nil
+		}
+		return err
+	}
+	return s.store.Delete(IndexItemType(itemType), name)
+}
+
+func (s storeIndexer) Query(itemType string, q Query) ([]Match, error) {
+	names, err := s.store.List(IndexItemType(itemType))
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Match
+	for _, name := range names {
+		data, err := s.store.Read(IndexItemType(itemType), name)
+		if err != nil {
+			return nil, err
+		}
+		var index Index
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, fmt.Errorf("error unmarshaling the index of %q: %v", name, err)
+		}
+		if q.matches(index) {
+			matches = append(matches, Match{Name: name, Index: index})
+		}
+	}
+
+	q.sort(matches)
+	return q.page(matches), nil
+}
+
+func (q Query) matches(index Index) bool {
+	for _, cond := range q.Conditions {
+		if !cond.matches(index[cond.Field]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Condition) matches(field interface{}) bool {
+	if field == nil {
+		return false
+	}
+	switch values := field.(type) {
+	case []interface{}:
+		for _, v := range values {
+			if c.matches(v) {
+				return true
+			}
+		}
+		return false
+	case []string:
+		for _, v := range values {
+			if c.matches(v) {
+				return true
+			}
+		}
+		return false
+	}
+
+	cmp, ok := compare(field, c.Value)
+	if !ok {
+		return false
+	}
+	switch c.Operator {
+	case OpEqual:
+		return cmp == 0
+	case OpGreaterOrEqual:
+		return cmp >= 0
+	case OpLess:
+		return cmp < 0
+	default:
+		return false
+	}
+}
+
+// compare compares two indexed values of the same kind. Numbers are compared
+// as float64, since they are decoded from JSON as such.
+func compare(a, b interface{}) (int, bool) {
+	if as, ok := a.(string); ok {
+		bs, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case as < bs:
+			return -1, true
+		case as > bs:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	af, ok := toFloat(a)
+	if !ok {
+		return 0, false
+	}
+	bf, ok := toFloat(b)
+	if !ok {
+		return 0, false
+	}
+	switch {
+	case af < bf:
+		return -1, true
+	case af > bf:
+		return 1, true
+	}
+	return 0, true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+func (q Query) sort(matches []Match) {
+	sort.SliceStable(matches, func(i, j int) bool {
+		if q.SortBy != "" {
+			cmp, _ := compare(matches[i].Index[q.SortBy], matches[j].Index[q.SortBy])
+			if cmp != 0 {
+				return (cmp < 0) != q.Descending
+			}
+		}
+		if matches[i].Name == matches[j].Name {
+			return false
+		}
+		return (matches[i].Name < matches[j].Name) != q.Descending
+	})
+}
+
+func (q Query) page(matches []Match) []Match {
+	if q.Skip >= len(matches) {
+		return nil
+	}
+	matches = matches[q.Skip:]
+	if q.Limit > 0 && q.Limit < len(matches) {
+		matches = matches[:q.Limit]
+	}
+	return matches
+}
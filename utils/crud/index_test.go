@@ -0,0 +1,76 @@
+package crud
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackingStore_Query(t *testing.T) {
+	tmdir, err := ioutil.TempDir("", "cnabgotest")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmdir)
+	s := NewBackingStore(NewFileSystemStore(tmdir, "json"))
+
+	indexes := map[string]Index{
+		"a": {"status": "failure", "modified": int64(30), "labels": []string{"team=red", "env=prod"}},
+		"b": {"status": "success", "modified": int64(10), "labels": []string{"team=red"}},
+		"c": {"status": "failure", "modified": int64(20)},
+		"d": {"status": "failure", "modified": int64(20), "labels": []string{"team=blue"}},
+	}
+	for name, index := range indexes {
+		require.NoError(t, s.SaveIndex("claims", name, index))
+	}
+
+	names := func(matches []Match) []string {
+		var names []string
+		for _, m := range matches {
+			names = append(names, m.Name)
+		}
+		return names
+	}
+	query := func(q Query) []string {
+		matches, err := s.Query("claims", q)
+		require.NoError(t, err)
+		return names(matches)
+	}
+
+	t.Run("sorted by name by default", func(t *testing.T) {
+		assert.Equal(t, []string{"a", "b", "c", "d"}, query(Query{}))
+	})
+
+	t.Run("conditions", func(t *testing.T) {
+		assert.Equal(t, []string{"a", "c", "d"}, query(Query{Conditions: []Condition{
+			{Field: "status", Operator: OpEqual, Value: "failure"},
+		}}))
+		assert.Equal(t, []string{"c", "d"}, query(Query{Conditions: []Condition{
+			{Field: "modified", Operator: OpGreaterOrEqual, Value: int64(15)},
+			{Field: "modified", Operator: OpLess, Value: int64(30)},
+		}}))
+	})
+
+	t.Run("list fields match any element", func(t *testing.T) {
+		assert.Equal(t, []string{"a", "b"}, query(Query{Conditions: []Condition{
+			{Field: "labels", Operator: OpEqual, Value: "team=red"},
+		}}))
+		assert.Equal(t, []string{"a"}, query(Query{Conditions: []Condition{
+			{Field: "labels", Operator: OpEqual, Value: "team=red"},
+			{Field: "labels", Operator: OpEqual, Value: "env=prod"},
+		}}))
+	})
+
+	t.Run("sort and paginate", func(t *testing.T) {
+		assert.Equal(t, []string{"a", "d", "c", "b"}, query(Query{SortBy: "modified", Descending: true}))
+		assert.Equal(t, []string{"c", "d"}, query(Query{SortBy: "modified", Skip: 1, Limit: 2}))
+		assert.Empty(t, query(Query{Skip: 4}))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, s.DeleteIndex("claims", "a"))
+		require.NoError(t, s.DeleteIndex("claims", "a"), "deleting a missing index should be a no-op")
+		assert.Equal(t, []string{"b", "c", "d"}, query(Query{}))
+	})
+}
@@ -0,0 +1,83 @@
+package crud
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrLockLost is returned when releasing a lock whose lease expired and was
+// broken by another owner.
+var ErrLockLost = errors.New("lock lost")
+
+// ErrLockingNotSupported is returned by a BackingStore whose store does not
+// implement Locker.
+var ErrLockingNotSupported = errors.New("the store does not support locking")
+
+// Locker is implemented by stores that support named locks with a lease.
+//
+// Locks are not reentrant: acquiring a lock that is already held fails, even
+// when it is held by the same owner. A lease that expired is considered stale,
+// and is broken by the next owner that acquires the lock.
+type Locker interface {
+	// Lock acquires the lock with the given name for ttl. It fails with a
+	// *LockedError when the lock is held by a lease that has not expired.
+	Lock(name, owner string, ttl time.Duration) (Lease, error)
+	// Unlock releases the lock held by the lease. It fails with ErrLockLost
+	// when the lease is no longer the one holding the lock.
+	Unlock(lease Lease) error
+}
+
+// Lease is the hold of an owner on a lock.
+type Lease struct {
+	Name     string    `json:"name"`
+	Owner    string    `json:"owner"`
+	ID       string    `json:"id"`
+	Acquired time.Time `json:"acquired"`
+	Expires  time.Time `json:"expires"`
+	// Stale is the expired lease that was broken to acquire this lease, if any.
+	Stale *Lease `json:"-"`
+}
+
+func newLease(name, owner string, ttl time.Duration) (Lease, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return Lease{}, fmt.Errorf("failed to generate lease ID: %v", err)
+	}
+	now := time.Now()
+	return Lease{
+		Name:     name,
+		Owner:    owner,
+		ID:       hex.EncodeToString(id),
+		Acquired: now,
+		Expires:  now.Add(ttl),
+	}, nil
+}
+
+// Expired reports whether the lease expired at the given time.
+func (l Lease) Expired(at time.Time) bool {
+	return !at.Before(l.Expires)
+}
+
+// LockedError is returned when acquiring a lock that is held by another lease.
+type LockedError struct {
+	Lease Lease
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%q is locked by %s since %s, until %s",
+		e.Lease.Name, e.Lease.Owner, e.Lease.Acquired.Format(time.RFC3339), e.Lease.Expires.Format(time.RFC3339))
+}
+
+// lockLost builds the error returned when releasing a lease that no longer
+// holds the lock, which is now held by current, or by nobody when nil.
+func lockLost(lease Lease, current *Lease) error {
+	if current == nil {
+		return fmt.Errorf("%w: the lease of %s on %q expired at %s and was broken",
+			ErrLockLost, lease.Owner, lease.Name, lease.Expires.Format(time.RFC3339))
+	}
+	return fmt.Errorf("%w: the lease of %s on %q expired at %s and was broken by %s",
+		ErrLockLost, lease.Owner, lease.Name, lease.Expires.Format(time.RFC3339), current.Owner)
+}
@@ -4,14 +4,18 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
 )
 
 // MongoCollectionPrefix is applied to every collection.
 const MongoCollectionPrefix = "cnab_"
 
 var _ Store = &mongoDBStore{}
+var _ Locker = &mongoDBStore{}
+var _ Indexer = &mongoDBStore{}
 
 type mongoDBStore struct {
 	url         string
@@ -27,7 +31,9 @@ type doc struct {
 
 // NewMongoDBStore creates a new storage engine that uses MongoDB
 //
-// The URL provided must point to a MongoDB server and database.
+// The URL provided must point to a MongoDB server and database. The store
+// implements Locker, with one document per lock, and Indexer, with one
+// document per index so that queries are evaluated by the server.
 func NewMongoDBStore(url string) Store {
 	db := &mongoDBStore{
 		url:         url,
@@ -85,7 +91,8 @@ func (s *mongoDBStore) List(itemType string) ([]string, error) {
 func (s *mongoDBStore) Save(itemType string, name string, data []byte) error {
 	collection := s.getCollection(itemType)
 
-	return wrapErr(collection.Insert(doc{name, data}))
+	_, err := collection.Upsert(map[string]string{"name": name}, doc{name, data})
+	return wrapErr(err)
 }
 
 func (s *mongoDBStore) Read(itemType string, name string) ([]byte, error) {
@@ -107,6 +114,93 @@ func (s *mongoDBStore) Delete(itemType string, name string) error {
 	return wrapErr(collection.Remove(map[string]string{"name": name}))
 }
 
+// lockCollection is the collection, before prefixing, holding the leases.
+const lockCollection = "locks"
+
+// lockDoc is a lease as stored in MongoDB, keyed by the name of the lock.
+type lockDoc struct {
+	Name     string    `bson:"_id"`
+	Owner    string    `bson:"owner"`
+	ID       string    `bson:"id"`
+	Acquired time.Time `bson:"acquired"`
+	Expires  time.Time `bson:"expires"`
+}
+
+func (d lockDoc) lease() Lease {
+	return Lease{Name: d.Name, Owner: d.Owner, ID: d.ID, Acquired: d.Acquired, Expires: d.Expires}
+}
+
+func newLockDoc(l Lease) lockDoc {
+	return lockDoc{Name: l.Name, Owner: l.Owner, ID: l.ID, Acquired: l.Acquired, Expires: l.Expires}
+}
+
+// Lock acquires the lock by inserting the lease, keyed by the name of the lock.
+// A stale lease is replaced with an update conditioned on its ID, so that only
+// one owner can break it.
+func (s *mongoDBStore) Lock(name, owner string, ttl time.Duration) (Lease, error) {
+	collection := s.getCollection(lockCollection)
+	lease, err := newLease(name, owner, ttl)
+	if err != nil {
+		return Lease{}, err
+	}
+
+	// Retry when the lock changes hands while we look at it.
+	for i := 0; i < 3; i++ {
+		err := collection.Insert(newLockDoc(lease))
+		if err == nil {
+			return lease, nil
+		}
+		if !mgo.IsDup(err) {
+			return Lease{}, wrapErr(err)
+		}
+
+		var held lockDoc
+		if err := collection.FindId(name).One(&held); err != nil {
+			if err == mgo.ErrNotFound {
+				continue
+			}
+			return Lease{}, wrapErr(err)
+		}
+		if !held.lease().Expired(time.Now()) {
+			return Lease{}, &LockedError{Lease: held.lease()}
+		}
+
+		err = collection.Update(bson.M{"_id": name, "id": held.ID}, newLockDoc(lease))
+		if err == nil {
+			stale := held.lease()
+			lease.Stale = &stale
+			return lease, nil
+		}
+		if err != mgo.ErrNotFound {
+			return Lease{}, wrapErr(err)
+		}
+	}
+	return Lease{}, fmt.Errorf("failed to acquire the lock on %q: the lock kept changing hands", name)
+}
+
+// Unlock releases the lock by removing the lease, if it still holds the lock.
+func (s *mongoDBStore) Unlock(lease Lease) error {
+	collection := s.getCollection(lockCollection)
+
+	err := collection.Remove(bson.M{"_id": lease.Name, "id": lease.ID})
+	if err == nil {
+		return nil
+	}
+	if err != mgo.ErrNotFound {
+		return wrapErr(err)
+	}
+
+	var current lockDoc
+	if err := collection.FindId(lease.Name).One(&current); err != nil {
+		if err == mgo.ErrNotFound {
+			return lockLost(lease, nil)
+		}
+		return wrapErr(err)
+	}
+	currentLease := current.lease()
+	return lockLost(lease, &currentLease)
+}
+
 func wrapErr(err error) error {
 	if err == nil {
 		return err
@@ -126,3 +220,102 @@ func parseDBName(dialStr string) (string, error) {
 	// default database.
 	return "", nil
 }
+
+// indexDoc is the index of a record as stored in MongoDB, with its fields
+// stored as they are so that queries are evaluated by the server.
+type indexDoc struct {
+	Name  string `bson:"_id"`
+	Index bson.M `bson:"index"`
+}
+
+func (s *mongoDBStore) SaveIndex(itemType, name string, index Index) error {
+	collection := s.getCollection(IndexItemType(itemType))
+
+	_, err := collection.UpsertId(name, indexDoc{Name: name, Index: bson.M(index)})
+	return wrapErr(err)
+}
+
+func (s *mongoDBStore) DeleteIndex(itemType, name string) error {
+	collection := s.getCollection(IndexItemType(itemType))
+
+	err := collection.RemoveId(name)
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return wrapErr(err)
+}
+
+func (s *mongoDBStore) Query(itemType string, q Query) ([]Match, error) {
+	collection := s.getCollection(IndexItemType(itemType))
+
+	filter, err := mongoFilter(q)
+	if err != nil {
+		return nil, err
+	}
+	query := collection.Find(filter).Sort(mongoSort(q)...).Skip(q.Skip)
+	if q.Limit > 0 {
+		query = query.Limit(q.Limit)
+	}
+
+	var docs []indexDoc
+	if err := query.All(&docs); err != nil {
+		return nil, wrapErr(err)
+	}
+	matches := make([]Match, len(docs))
+	for i, d := range docs {
+		matches[i] = Match{Name: d.Name, Index: Index(d.Index)}
+	}
+	return matches, nil
+}
+
+// mongoFilter translates the conditions of a query to a MongoDB filter. An
+// equality on an array field matches any of its elements, as Condition does.
+func mongoFilter(q Query) (bson.M, error) {
+	filter := bson.M{}
+	for _, cond := range q.Conditions {
+		field := "index." + cond.Field
+		var op string
+		switch cond.Operator {
+		case OpEqual:
+			op = "$eq"
+		case OpGreaterOrEqual:
+			op = "$gte"
+		case OpLess:
+			op = "$lt"
+		default:
+			return nil, fmt.Errorf("unsupported query operator %q", cond.Operator)
+		}
+
+		ops, ok := filter[field].(bson.M)
+		if !ok {
+			ops = bson.M{}
+			filter[field] = ops
+		}
+		if _, exists := ops[op]; exists {
+			// Several equalities on the same field, such as two labels, must
+			// all hold.
+			filter["$and"] = append(andClauses(filter), bson.M{field: bson.M{op: cond.Value}})
+			continue
+		}
+		ops[op] = cond.Value
+	}
+	return filter, nil
+}
+
+func andClauses(filter bson.M) []interface{} {
+	clauses, _ := filter["$and"].([]interface{})
+	return clauses
+}
+
+// mongoSort returns the sort fields of a query, with the record names breaking ties.
+func mongoSort(q Query) []string {
+	prefix := ""
+	if q.Descending {
+		prefix = "-"
+	}
+	var fields []string
+	if q.SortBy != "" {
+		fields = append(fields, prefix+"index."+q.SortBy)
+	}
+	return append(fields, prefix+"_id")
+}
@@ -3,7 +3,9 @@ package crud
 import (
 	"testing"
 
+	"github.com/globalsign/mgo/bson"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParseDBName(t *testing.T) {
@@ -20,3 +22,28 @@ func TestParseDBName(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, "", name)
 }
+
+func TestMongoFilter(t *testing.T) {
+	filter, err := mongoFilter(Query{Conditions: []Condition{
+		{Field: "status", Operator: OpEqual, Value: "failure"},
+		{Field: "modified", Operator: OpGreaterOrEqual, Value: int64(10)},
+		{Field: "modified", Operator: OpLess, Value: int64(20)},
+		{Field: "labels", Operator: OpEqual, Value: "team=red"},
+		{Field: "labels", Operator: OpEqual, Value: "env=prod"},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, bson.M{
+		"index.status":   bson.M{"$eq": "failure"},
+		"index.modified": bson.M{"$gte": int64(10), "$lt": int64(20)},
+		"index.labels":   bson.M{"$eq": "team=red"},
+		"$and":           []interface{}{bson.M{"index.labels": bson.M{"$eq": "env=prod"}}},
+	}, filter)
+
+	_, err = mongoFilter(Query{Conditions: []Condition{{Field: "status", Operator: "ne", Value: "x"}}})
+	assert.EqualError(t, err, `unsupported query operator "ne"`)
+}
+
+func TestMongoSort(t *testing.T) {
+	assert.Equal(t, []string{"_id"}, mongoSort(Query{}))
+	assert.Equal(t, []string{"-index.modified", "-_id"}, mongoSort(Query{SortBy: "modified", Descending: true}))
+}